@@ -0,0 +1,162 @@
+// build.go
+package main
+
+import (
+	"context"
+	"cvforge/config"
+	"cvforge/engine"
+	"cvforge/types"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newBuildCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "build [target...]",
+		Short: "Build named targets from cvforge.yaml",
+		Long: `Build one, several or all of the targets declared in the project config
+file. Without arguments every target is built, in the order it is declared.`,
+		RunE: runBuild,
+	}
+}
+
+func runBuild(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		return fmt.Errorf("no config file found (looked for %s)", config.DefaultFile)
+	}
+
+	names := args
+	if len(names) == 0 {
+		names = cfg.TargetNames()
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no targets defined in config file")
+	}
+	for _, name := range names {
+		if _, ok := cfg.Targets[name]; !ok {
+			return fmt.Errorf("unknown target: %s (available: %s)", name, strings.Join(cfg.TargetNames(), ", "))
+		}
+	}
+
+	// Started on the first PDF target and shared by the rest
+	var renderer *engine.PDFRenderer
+	defer func() {
+		if renderer != nil {
+			renderer.Close()
+		}
+	}()
+
+	failed := 0
+	for _, name := range names {
+		if err := cmd.Context().Err(); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		output, err := buildTarget(cmd.Context(), cfg, name, cfg.Targets[name], &renderer)
+		if err != nil {
+			fmt.Printf("❌ %s: %s\n", name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("✅ %s → %s (%s)\n", name, output, time.Since(start).Round(time.Millisecond))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d targets failed", failed, len(names))
+	}
+	fmt.Printf("✨ %d targets built successfully\n", len(names))
+	return nil
+}
+
+// buildTarget renders a single target and returns the path it was written to
+func buildTarget(ctx context.Context, cfg *config.Config, name string, target config.Target, renderer **engine.PDFRenderer) (string, error) {
+	if target.Template == "" {
+		return "", fmt.Errorf("no template set")
+	}
+	if len(target.Data) == 0 {
+		return "", fmt.Errorf("no data file set")
+	}
+
+	format := target.Format
+	if format == "" {
+		format = "pdf"
+		if ext := strings.TrimPrefix(filepath.Ext(target.Output), "."); ext != "" {
+			format = ext
+		}
+	}
+	outputFormat, err := parseFormat(format)
+	if err != nil {
+		return "", err
+	}
+
+	output := target.Output
+	if output == "" {
+		output = fmt.Sprintf("%s.%s", name, outputFormat)
+	}
+	output = cfg.Resolve(output)
+
+	dataPaths := make([]string, len(target.Data))
+	for i, path := range target.Data {
+		dataPaths[i] = cfg.Resolve(path)
+	}
+	data, profiles, err := loadData(cfg.Resolve(target.Schema), dataPaths...)
+	if err != nil {
+		return "", fmt.Errorf("failed to load data: %w", err)
+	}
+	filter := tagFilter{Tags: target.Tags, Expr: target.TagsExpr, Profile: target.Profile, Inherit: target.InheritTags}
+	data, expr, err := filter.apply(data, profiles)
+	if err != nil {
+		return "", err
+	}
+
+	renderOpts := engine.RenderOptions{Filter: expr, MaxPages: target.MaxPages, Warn: printWarning}
+	if target.Assets != "" {
+		renderOpts.AssetRoots = []string{cfg.Resolve(target.Assets)}
+	}
+	if outputFormat == engine.OutputPDF {
+		pdfOpts := engine.DefaultPDFOptions()
+		pdfOpts.PreferCSSPageSize = true
+		if err := cfg.PDF.Apply(&pdfOpts, cfg.Dir); err != nil {
+			return "", fmt.Errorf("invalid pdf options: %w", err)
+		}
+		if target.PDF != nil {
+			if err := target.PDF.Apply(&pdfOpts, cfg.Dir); err != nil {
+				return "", fmt.Errorf("invalid pdf options: %w", err)
+			}
+		}
+		renderOpts.PDF = &pdfOpts
+
+		if *renderer == nil {
+			if *renderer, err = engine.NewPDFRenderer(1); err != nil {
+				return "", fmt.Errorf("failed to start PDF renderer: %w", err)
+			}
+		}
+		renderOpts.Renderer = *renderer
+	}
+
+	var dropped []types.ListItem
+	renderOpts.Dropped = func(items []types.ListItem) { dropped = items }
+	result, err := engine.Render(ctx, cfg.Resolve(target.Template), data, outputFormat, renderOpts)
+	if err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	printDropped(dropped, renderOpts.MaxPages)
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(output, result, 0644); err != nil {
+		return "", fmt.Errorf("failed to write output: %w", err)
+	}
+	return output, nil
+}
@@ -0,0 +1,196 @@
+// Package config loads the project configuration file (cvforge.yaml).
+package config
+
+import (
+	"bytes"
+	"cvforge/engine"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the config file looked up in the working directory
+const DefaultFile = "cvforge.yaml"
+
+// Config is the contents of a cvforge.yaml file
+type Config struct {
+	// PDF holds project-wide PDF options; targets may override them
+	PDF     PDF               `yaml:"pdf,omitempty"`
+	Targets map[string]Target `yaml:"targets,omitempty"`
+
+	// targetOrder lists target names in the order they appear in the file
+	targetOrder []string
+
+	// Dir is the directory of the config file; relative paths in the file
+	// are resolved against it
+	Dir string `yaml:"-"`
+}
+
+// Target is one named build of the CV
+type Target struct {
+	Template string     `yaml:"template"`
+	Data     StringList `yaml:"data"`
+	Tags     []string   `yaml:"tags,omitempty"`
+	TagsExpr string     `yaml:"tags-expr,omitempty"`
+	Profile  string     `yaml:"profile,omitempty"`
+	Schema   string     `yaml:"schema,omitempty"`
+	Format   string     `yaml:"format,omitempty"`
+	Output   string     `yaml:"output"`
+	Assets   string     `yaml:"assets,omitempty"`
+	PDF      *PDF       `yaml:"pdf,omitempty"`
+
+	// InheritTags gives untagged values the tags of their parent
+	InheritTags bool `yaml:"inherit-tags,omitempty"`
+	// MaxPages trims PDF output to this many pages
+	MaxPages int `yaml:"max-pages,omitempty"`
+}
+
+// StringList accepts either a single string or a list of strings
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = StringList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// PDF holds PDF output settings. Keys mirror the CLI flags of the same name.
+type PDF struct {
+	Paper          string `yaml:"paper,omitempty"`
+	Margin         string `yaml:"margin,omitempty"`
+	Landscape      *bool  `yaml:"landscape,omitempty"`
+	HeaderTemplate string `yaml:"header-template,omitempty"`
+	FooterTemplate string `yaml:"footer-template,omitempty"`
+	// FitPages shrinks the document, down to MinScale, until it takes at most
+	// this many pages
+	FitPages int     `yaml:"fit-pages,omitempty"`
+	MinScale float64 `yaml:"min-scale,omitempty"`
+}
+
+// Load reads and parses the config file at path
+func Load(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	// Map decoding loses order, so read target names from the node tree
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err == nil && len(doc.Content) > 0 {
+		root := doc.Content[0]
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value != "targets" || root.Content[i+1].Kind != yaml.MappingNode {
+				continue
+			}
+			targets := root.Content[i+1].Content
+			for j := 0; j < len(targets); j += 2 {
+				cfg.targetOrder = append(cfg.targetOrder, targets[j].Value)
+			}
+		}
+	}
+
+	if cfg.Dir, err = filepath.Abs(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Find returns the path of DefaultFile in the working directory, if present
+func Find() (string, bool) {
+	if stat, err := os.Stat(DefaultFile); err == nil && !stat.IsDir() {
+		return DefaultFile, true
+	}
+	return "", false
+}
+
+// TargetNames returns every target name in file order
+func (c *Config) TargetNames() []string {
+	names := make([]string, 0, len(c.Targets))
+	for _, name := range c.targetOrder {
+		if _, ok := c.Targets[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Resolve makes a path from the config file relative to the file's directory
+func (c *Config) Resolve(path string) string {
+	if path == "" || filepath.IsAbs(path) || c.Dir == "" {
+		return path
+	}
+	return filepath.Join(c.Dir, path)
+}
+
+// Apply sets every option present in p on opts. Header and footer template
+// file paths are resolved against baseDir.
+func (p PDF) Apply(opts *engine.PDFOptions, baseDir string) error {
+	if p.Paper != "" {
+		if err := opts.SetPaper(p.Paper); err != nil {
+			return err
+		}
+	}
+	if p.Margin != "" {
+		if err := opts.SetMargins(p.Margin); err != nil {
+			return err
+		}
+	}
+	if p.Landscape != nil {
+		opts.Landscape = *p.Landscape
+	}
+	if p.FitPages < 0 {
+		return fmt.Errorf("invalid fit-pages value: %d", p.FitPages)
+	}
+	if p.FitPages > 0 {
+		opts.FitPages = p.FitPages
+	}
+	if p.MinScale != 0 {
+		if p.MinScale < 0.1 || p.MinScale > 1 {
+			return fmt.Errorf("invalid min-scale value: %g (must be between 0.1 and 1)", p.MinScale)
+		}
+		opts.MinScale = p.MinScale
+	}
+	if p.HeaderTemplate != "" {
+		if err := opts.SetHeaderTemplate(resolveTemplate(p.HeaderTemplate, baseDir)); err != nil {
+			return fmt.Errorf("failed to read header template: %w", err)
+		}
+	}
+	if p.FooterTemplate != "" {
+		if err := opts.SetFooterTemplate(resolveTemplate(p.FooterTemplate, baseDir)); err != nil {
+			return fmt.Errorf("failed to read footer template: %w", err)
+		}
+	}
+	return nil
+}
+
+// resolveTemplate joins a header/footer file path with baseDir when that
+// file exists, leaving inline HTML untouched
+func resolveTemplate(tmpl, baseDir string) string {
+	if baseDir == "" || strings.ContainsAny(tmpl, "<>") || filepath.IsAbs(tmpl) {
+		return tmpl
+	}
+	path := filepath.Join(baseDir, tmpl)
+	if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+		return path
+	}
+	return tmpl
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"cvforge/types"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// attrPrefix marks attribute-binding directives: attr-href="email" sets the
+// href attribute from the email field.
+const attrPrefix = "attr-"
+
+// bindAttributes processes every attr-* directive on s. The directive value
+// is either a single data path or a pattern with {path} placeholders such as
+// "mailto:{email}"; either kind of path may be followed by formatters.
+// Values are escaped when the document is written; event handler attributes
+// are never bound and URL attributes only accept safe schemes.
+func bindAttributes(s *goquery.Selection, resolve func(path string) types.CVBase, warn warnFunc) {
+	if len(s.Nodes) == 0 {
+		return
+	}
+	var bindings [][2]string
+	for _, attr := range s.Nodes[0].Attr {
+		if strings.HasPrefix(attr.Key, attrPrefix) && len(attr.Key) > len(attrPrefix) {
+			bindings = append(bindings, [2]string{attr.Key, attr.Val})
+		}
+	}
+
+	for _, b := range bindings {
+		s.RemoveAttr(b[0])
+		target := strings.ToLower(b[0][len(attrPrefix):])
+		// Data never reaches event handlers, and URLs must not run script
+		if strings.HasPrefix(target, "on") {
+			continue
+		}
+		value, ok := expandAttrBinding(b[1], resolve)
+		if ok && urlAttributes[target] {
+			safe := safeURL(value)
+			if imageAttributes[target] {
+				safe = safeImageURL(value)
+			}
+			if safe == "" && strings.TrimSpace(value) != "" {
+				warn("%s: %s %.60q left out: unsafe URL", describeElement(s), b[0], value)
+			}
+			value, ok = safe, safe != ""
+		}
+		if ok {
+			s.SetAttr(target, value)
+		}
+	}
+}
+
+// expandAttrBinding resolves an attr-* directive value to the attribute text.
+func expandAttrBinding(expr string, resolve func(path string) types.CVBase) (string, bool) {
+	expr = strings.TrimSpace(expr)
+	if !strings.Contains(expr, "{") {
+		value := resolvePipeline(expr, resolve)
+		if value == nil {
+			return "", false
+		}
+		return getStringValue(value), true
+	}
+
+	var sb strings.Builder
+	rest := expr
+	for {
+		start := strings.Index(rest, "{")
+		if start == -1 {
+			sb.WriteString(rest)
+			break
+		}
+		end := strings.Index(rest[start:], "}")
+		if end == -1 {
+			return "", false
+		}
+		end += start
+
+		value := resolvePipeline(rest[start+1:end], resolve)
+		if value == nil {
+			return "", false
+		}
+		sb.WriteString(rest[:start])
+		sb.WriteString(getStringValue(value))
+		rest = rest[end+1:]
+	}
+	return sb.String(), true
+}
+
+// insideNestedRepeat reports whether s is, or sits below, a repeat-for
+// element nested inside root. Such nodes are bound by the inner loop.
+func insideNestedRepeat(s, root *goquery.Selection) bool {
+	rootNode := root.Get(0)
+	for n := s.Get(0); n != nil && n != rootNode; n = n.Parent {
+		for _, attr := range n.Attr {
+			if attr.Key == "repeat-for" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"cvforge/types"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Conditional directives. if-missing is the negation of if-exists, if
+// evaluates an expression (see parseExpr), and an else element is kept
+// only when the conditional sibling before it was removed:
+//
+//	<a if-exists="email" attr-href="mailto:{email}">Email</a>
+//	<span else>Available on request</span>
+const (
+	ifExistsAttr  = "if-exists"
+	ifMissingAttr = "if-missing"
+	ifAttr        = "if"
+	elseAttr      = "else"
+)
+
+// chainState is where a run of conditional siblings stands
+type chainState int
+
+const (
+	chainNone     chainState = iota // the previous sibling was not conditional
+	chainTaken                      // a branch of the chain was kept
+	chainNotTaken                   // every branch so far was removed
+)
+
+// applyConditions evaluates the conditional directives of s, advancing
+// chain, and reports whether s should be kept. An else element may carry
+// its own if-exists, if-missing or if to continue the chain. On a repeat-for
+// element if filters the items instead and is left for
+// applyRepeatModifiers. Expressions are checked by checkExpressions before
+// rendering, so one that does not parse here is simply false.
+func applyConditions(s *goquery.Selection, context types.CVBase, chain *chainState) bool {
+	_, isElse := s.Attr(elseAttr)
+	ifExists, hasExists := s.Attr(ifExistsAttr)
+	ifMissing, hasMissing := s.Attr(ifMissingAttr)
+	ifExpr, hasExpr := s.Attr(ifAttr)
+	s.RemoveAttr(elseAttr)
+	s.RemoveAttr(ifExistsAttr)
+	s.RemoveAttr(ifMissingAttr)
+	if _, repeat := s.Attr("repeat-for"); repeat {
+		hasExpr = false
+	} else {
+		s.RemoveAttr(ifAttr)
+	}
+
+	if isElse && *chain == chainTaken {
+		// An earlier branch was kept; skip the rest of the chain
+		return false
+	}
+	if !hasExists && !hasMissing && !hasExpr {
+		// A plain element, or the final else of a chain
+		*chain = chainNone
+		return true
+	}
+
+	keep := (!hasExists || checkIfExists(s, context, ifExists)) &&
+		(!hasMissing || !checkIfExists(s, context, ifMissing))
+	if keep && hasExpr {
+		keep, _ = evalCondition(ifExpr, context)
+	}
+	if keep {
+		*chain = chainTaken
+	} else {
+		*chain = chainNotTaken
+	}
+	return keep
+}
+
+// checkExpressions parses every if expression below root and checks that
+// the data paths of other directives are not written as expressions,
+// naming the element of the first one that fails
+func checkExpressions(root *goquery.Selection) error {
+	var err error
+	root.Find("*").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		for _, attr := range s.Nodes[0].Attr {
+			if attr.Key == ifAttr {
+				if _, perr := parseExpr(attr.Val); perr != nil {
+					err = fmt.Errorf("%s: %w", describeElement(s), perr)
+					return false
+				}
+				continue
+			}
+			for _, path := range directivePaths(attr.Key, attr.Val) {
+				if perr := checkPathSyntax(path); perr != nil {
+					err = fmt.Errorf("%s: %s: %w", describeElement(s), attr.Key, perr)
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return err
+}
+
+// directivePaths returns the data paths the directive key refers to, with
+// any formatters left off
+func directivePaths(key, value string) []string {
+	var exprs []string
+	switch {
+	case slices.Contains(contentAttrs, key):
+		exprs = []string{value}
+	case strings.HasPrefix(key, attrPrefix):
+		exprs, _ = bindingPaths(value)
+	case key == ifExistsAttr, key == ifMissingAttr, key == "repeat-for", key == groupByAttr, key == sortByAttr:
+		return []string{value}
+	}
+	paths := make([]string, len(exprs))
+	for i, expr := range exprs {
+		paths[i], _ = splitPipeline(expr)
+	}
+	return paths
+}
+
+// describeElement identifies s in error messages, as in <li class="job">
+func describeElement(s *goquery.Selection) string {
+	var sb strings.Builder
+	sb.WriteString("<" + goquery.NodeName(s))
+	for _, attr := range []string{"id", "class"} {
+		if value, ok := s.Attr(attr); ok {
+			fmt.Fprintf(&sb, " %s=%q", attr, value)
+		}
+	}
+	sb.WriteString(">")
+	return sb.String()
+}
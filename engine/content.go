@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"cvforge/types"
+	"html"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Content directives: value-of escapes data, value-of-html inserts it as
+// HTML and should only be used for trusted content, value-md renders it as
+// sanitized markdown
+const (
+	valueOfAttr       = "value-of"
+	valueOfHTMLAttr   = "value-of-html"
+	valueMarkdownAttr = "value-md"
+)
+
+// contentAttrs lists the content directives in the order they are applied
+var contentAttrs = []string{valueOfAttr, valueOfHTMLAttr, valueMarkdownAttr}
+
+// contentSelector matches elements carrying any content directive
+const contentSelector = "[value-of], [value-of-html], [value-md]"
+
+// joinAttr sets the separator a content directive puts between the items of
+// a list or the pairs of a map: join=" · ". A \n in it is a line break.
+const joinAttr = "join"
+
+// defaultJoin separates items when no join attribute is given
+const defaultJoin = ", "
+
+// Data formats a value can declare with its format meta key
+const (
+	FormatText     = "text"
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+)
+
+// attrFormat returns the format a content directive forces, or "" when the
+// value's own format applies
+func attrFormat(attr string) string {
+	switch attr {
+	case valueOfHTMLAttr:
+		return FormatHTML
+	case valueMarkdownAttr:
+		return FormatMarkdown
+	}
+	return ""
+}
+
+// setContent replaces the content of s with cv, wrapped in a link when cv
+// has a URL. format overrides the format cv declares; text is escaped. The
+// join attribute of s, if any, separates list items and map pairs.
+func setContent(s *goquery.Selection, cv types.CVBase, format string, warn warnFunc) {
+	sep := html.EscapeString(defaultJoin)
+	if join, ok := s.Attr(joinAttr); ok {
+		sep = joinSeparator(join)
+		s.RemoveAttr(joinAttr)
+	}
+	content := contentHTML(cv, format, sep)
+	if u := getURL(cv); u != "" {
+		if link := safeURL(u); link != "" {
+			s.SetHtml(`<a href="` + html.EscapeString(link) + `">` + content + `</a>`)
+			return
+		}
+		warn("%s: url %.60q left out: unsafe URL", describeElement(s), u)
+	}
+	s.SetHtml(content)
+}
+
+// contentHTML returns cv as HTML. Slices are joined item by item so each
+// keeps its own format, and maps become "key: value" pairs; sep is the
+// HTML put between them. Nested lists use the default separator.
+func contentHTML(cv types.CVBase, format, sep string) string {
+	switch v := cv.(type) {
+	case types.CVForgeString:
+		if format == "" {
+			format = v.Format
+		}
+		switch format {
+		case FormatHTML:
+			return v.Value
+		case FormatMarkdown:
+			return renderMarkdown(v.Value)
+		}
+		return html.EscapeString(v.Value)
+	case types.CVForgeSlice:
+		parts := make([]string, 0, len(v.Value))
+		for _, item := range v.Value {
+			parts = append(parts, contentHTML(item, format, html.EscapeString(defaultJoin)))
+		}
+		return strings.Join(parts, sep)
+	case types.CVForgeMap:
+		var parts []string
+		for _, key := range mapContentKeys(v) {
+			value := contentHTML(v.Value[key], format, html.EscapeString(defaultJoin))
+			if strings.TrimSpace(value) != "" {
+				parts = append(parts, html.EscapeString(key)+": "+value)
+			}
+		}
+		return strings.Join(parts, sep)
+	}
+	return html.EscapeString(getStringValue(cv))
+}
+
+// joinSeparator returns the HTML for a join attribute: the text escaped,
+// with line breaks, written as a newline or \n, turned into <br>
+func joinSeparator(join string) string {
+	join = strings.ReplaceAll(join, `\n`, "\n")
+	lines := strings.Split(join, "\n")
+	for i, line := range lines {
+		lines[i] = html.EscapeString(line)
+	}
+	return strings.Join(lines, "<br>")
+}
+
+// urlAttributes are attributes whose value the browser loads or navigates to
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true,
+	"poster": true, "background": true, "xlink:href": true,
+}
+
+// imageAttributes are URL attributes that load an image, which may also
+// be embedded as a data:image URL
+var imageAttributes = map[string]bool{"src": true, "poster": true}
+
+// safeURL returns u unless it uses a scheme that can run script, such as
+// javascript:. Relative URLs and http(s), mailto and tel links pass.
+func safeURL(u string) string {
+	u = strings.TrimSpace(u)
+	switch urlScheme(u) {
+	case "", "http", "https", "mailto", "tel":
+		return u
+	}
+	return ""
+}
+
+// safeImageURL is safeURL that also accepts images embedded as data URLs.
+// SVG is refused because it can carry script.
+func safeImageURL(u string) string {
+	u = strings.TrimSpace(u)
+	if urlScheme(u) == "data" {
+		mediaType := strings.ToLower(strings.TrimSpace(u[len("data:"):]))
+		if strings.HasPrefix(mediaType, "image/") && !strings.HasPrefix(mediaType, "image/svg") {
+			return u
+		}
+		return ""
+	}
+	return safeURL(u)
+}
+
+// urlScheme returns the lowercase scheme of u, or "" when u is relative.
+// Browsers ignore tabs and newlines anywhere in a URL, so they are ignored
+// here too, and java\tscript: is still javascript:.
+func urlScheme(u string) string {
+	u = strings.NewReplacer("\t", "", "\n", "", "\r", "").Replace(u)
+	u = strings.TrimLeftFunc(u, func(r rune) bool { return r <= ' ' })
+	for i := 0; i < len(u); i++ {
+		switch c := u[i]; {
+		case c == ':' && i > 0:
+			return strings.ToLower(u[:i])
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			// A path, query or fragment comes first: a relative URL
+			return ""
+		}
+	}
+	return ""
+}
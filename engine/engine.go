@@ -2,11 +2,14 @@ package engine
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 
 	"cvforge/types"
-	"fmt"
 
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -22,8 +25,54 @@ const (
 	OutputPDF  OutputFormat = "pdf"
 )
 
+// RenderOptions controls how Render produces its output
+type RenderOptions struct {
+	// Renderer prints PDF output. When nil, a browser is started for the
+	// call; share one PDFRenderer across calls when rendering many documents.
+	Renderer *PDFRenderer
+	// AssetRoots are extra directories served after the template's own
+	// directory, so relative asset URLs resolve during PDF generation.
+	AssetRoots []string
+	// PDF sets paper size, margins and headers for PDF output. When nil,
+	// A4 is used unless the template's CSS sets its own @page size.
+	PDF *PDFOptions
+	// Filter is the tag filter the data was narrowed with. Elements with an
+	// if-tag directive are kept only when it matches their tags; when nil,
+	// every element is kept.
+	Filter types.TagExpr
+	// MaxPages limits PDF output to this many pages by dropping the lowest
+	// priority list items; see FitPages. Zero means no limit.
+	MaxPages int
+	// Dropped, when set, is given the list items MaxPages made Render drop
+	Dropped func(items []types.ListItem)
+	// Warn, when set, is told about data left out of the output, such as a
+	// link with an unsafe URL
+	Warn func(message string)
+}
+
+// warnFunc reports data left out of the output; see RenderOptions.Warn
+type warnFunc func(format string, args ...any)
+
+func (opts RenderOptions) warnf(format string, args ...any) {
+	if opts.Warn != nil {
+		opts.Warn(fmt.Sprintf(format, args...))
+	}
+}
+
 // Render renders HTML template with data and outputs in specified format
-func Render(templatePath string, data types.CVBase, format OutputFormat) ([]byte, error) {
+func Render(ctx context.Context, templatePath string, data types.CVBase, format OutputFormat, opts RenderOptions) ([]byte, error) {
+	if format == OutputPDF && opts.MaxPages > 0 {
+		result, dropped, err := FitPages(ctx, templatePath, data, opts)
+		if err == nil && opts.Dropped != nil {
+			opts.Dropped(dropped)
+		}
+		return result, err
+	}
+	return render(ctx, templatePath, data, format, opts)
+}
+
+// render renders the template once
+func render(ctx context.Context, templatePath string, data types.CVBase, format OutputFormat, opts RenderOptions) ([]byte, error) {
 	file, err := os.Open(templatePath)
 	if err != nil {
 		return nil, err
@@ -35,8 +84,16 @@ func Render(templatePath string, data types.CVBase, format OutputFormat) ([]byte
 		return nil, err
 	}
 
-	// Process the document starting from root
-	processNode(doc.Selection, data)
+	// Toggle static sections for the active tag filter, then process the
+	// document starting from root
+	applyIfTag(doc.Selection, opts.Filter)
+	if err := checkExpressions(doc.Selection); err != nil {
+		return nil, err
+	}
+	if err := checkPipelines(doc.Selection); err != nil {
+		return nil, err
+	}
+	processNode(doc.Selection, data, opts.warnf)
 
 	htmlContent, err := doc.Html()
 	if err != nil {
@@ -45,46 +102,76 @@ func Render(templatePath string, data types.CVBase, format OutputFormat) ([]byte
 
 	switch format {
 	case OutputPDF:
-		return GeneratePDF(htmlContent)
+		renderer := opts.Renderer
+		if renderer == nil {
+			if renderer, err = NewPDFRenderer(1); err != nil {
+				return nil, err
+			}
+			defer renderer.Close()
+		}
+		roots := append([]string{filepath.Dir(templatePath)}, opts.AssetRoots...)
+		pdfOpts := DefaultPDFOptions()
+		pdfOpts.PreferCSSPageSize = true
+		if opts.PDF != nil {
+			pdfOpts = *opts.PDF
+		}
+		return renderer.Generate(ctx, htmlContent, pdfOpts, roots...)
 	default:
 		return []byte(htmlContent), nil
 	}
 }
 
+// ifTagAttr keeps an element only for variants whose tag filter would keep
+// data tagged the same way: if-tag="go, backend"
+const ifTagAttr = "if-tag"
+
+// applyIfTag removes the if-tag elements below root that filter rejects
+func applyIfTag(root *goquery.Selection, filter types.TagExpr) {
+	root.Find("[" + ifTagAttr + "]").Each(func(i int, s *goquery.Selection) {
+		tags, _ := s.Attr(ifTagAttr)
+		s.RemoveAttr(ifTagAttr)
+		if filter != nil && !filter.Match(types.SplitTags(tags)) {
+			s.Remove()
+		}
+	})
+}
+
 // processNode recursively processes HTML nodes
-func processNode(node *goquery.Selection, context types.CVBase) {
+func processNode(node *goquery.Selection, context types.CVBase, warn warnFunc) {
+	// chain tracks the if-exists/if-missing/if/else siblings seen so far
+	chain := chainNone
 	node.Each(func(i int, s *goquery.Selection) {
-		if ifExist, exists := s.Attr("if-exists"); exists {
-			if !checkIfExists(s, context, ifExist) {
-				s.Remove()
-				return // Node removed, no further processing needed
-			}
-			s.RemoveAttr("if-exists") // Remove attribute, continue processing
+		if !applyConditions(s, context, &chain) {
+			s.Remove()
+			return // Node removed, no further processing needed
 		}
 		// Process repeat-for first (it replaces the node)
 		if repeatFor, exists := s.Attr("repeat-for"); exists {
-			processRepeatFor(s, context, repeatFor)
+			processRepeatFor(s, context, repeatFor, warn)
 			return
 		}
 
-		// Process value-of
-		if valueOf, exists := s.Attr("value-of"); exists {
-			cvValue := getCVBaseFromPath(context, valueOf)
-			if cvValue != nil {
-				content := getStringValue(cvValue)
-				url := getURL(cvValue)
-
-				if url != "" {
-					s.SetHtml(fmt.Sprintf(`<a href="%s">%s</a>`, url, content))
-				} else {
-					s.SetHtml(content)
+		// Process attr-* bindings
+		bindAttributes(s, func(path string) types.CVBase {
+			return getCVBaseFromPath(context, path)
+		}, warn)
+
+		// Process value-of, value-of-html and value-md
+		for _, attr := range contentAttrs {
+			if valueOf, exists := s.Attr(attr); exists {
+				cvValue := resolvePipeline(valueOf, func(path string) types.CVBase {
+					return getCVBaseFromPath(context, path)
+				})
+				if cvValue != nil {
+					setContent(s, cvValue, attrFormat(attr), warn)
 				}
+				s.RemoveAttr(attr)
 			}
-			s.RemoveAttr("value-of")
 		}
+		s.RemoveAttr(joinAttr)
 
 		// Process children recursively
-		processNode(s.Children(), context)
+		processNode(s.Children(), context, warn)
 	})
 }
 func checkIfExists(node *goquery.Selection, context types.CVBase, path string) bool {
@@ -114,7 +201,7 @@ func checkIfExists(node *goquery.Selection, context types.CVBase, path string) b
 }
 
 // processRepeatFor handles repeat-for attribute for collections
-func processRepeatFor(node *goquery.Selection, context types.CVBase, repeatPath string) {
+func processRepeatFor(node *goquery.Selection, context types.CVBase, repeatPath string, warn warnFunc) {
 	parent := node.Parent()
 
 	// Get the collection value
@@ -127,27 +214,49 @@ func processRepeatFor(node *goquery.Selection, context types.CVBase, repeatPath
 	}
 
 	var collection []types.CVBase
+	var keys []string
 
 	// Handle different value types
 	switch v := value.(type) {
 	case types.CVForgeSlice:
 		collection = v.Value
+	case types.CVForgeMap:
+		// Iterate map entries in the order they were written
+		for _, k := range v.OrderedKeys() {
+			collection = append(collection, v.Value[k])
+			keys = append(keys, k)
+		}
 	case types.CVForgeString:
 		// Split comma-separated string
 		if v.Value != "" {
 			parts := strings.Split(v.Value, ",")
 			for _, part := range parts {
-				collection = append(collection, &types.CVForgeString{Value: strings.TrimSpace(part)})
+				collection = append(collection, types.CVForgeString{CVTagInfo: v.CVTagInfo, Value: strings.TrimSpace(part)})
 			}
 		}
 	case types.CVBase:
 		collection = []types.CVBase{v}
 	}
 
-	if len(collection) == 0 {
+	// Apply sort-by, order, group-by, if, offset and limit before positions
+	// are assigned
+	entries := make([]repeatEntry, len(collection))
+	for i, item := range collection {
+		entries[i].item = item
+		if keys != nil {
+			entries[i].key = keys[i]
+		}
+	}
+	entries, grouped := applyRepeatModifiers(node, context, repeatPath, entries, keys != nil)
+
+	if len(entries) == 0 {
 		node.Remove()
 		return
 	}
+	for _, attr := range repeatModifiers {
+		node.RemoveAttr(attr)
+	}
+	node.RemoveAttr(ifAttr)
 
 	// Get outer HTML of template node
 	templateHTML := getOuterHTML(node)
@@ -156,7 +265,14 @@ func processRepeatFor(node *goquery.Selection, context types.CVBase, repeatPath
 	node.Remove()
 	ignore := false
 	// Create a clone for each item
-	for _, item := range collection {
+	for idx, entry := range entries {
+		item := entry.item
+		pos := loopPosition{index: idx, count: len(entries), parent: context, group: grouped}
+		if keys != nil || grouped {
+			pos.key, pos.hasKey = entry.key, true
+		}
+		scope := loopScope(item, pos)
+
 		// Parse template HTML
 		itemDoc, err := goquery.NewDocumentFromReader(strings.NewReader(templateHTML))
 		if err != nil {
@@ -169,43 +285,53 @@ func processRepeatFor(node *goquery.Selection, context types.CVBase, repeatPath
 		}
 		clone.RemoveAttr("repeat-for")
 
-		// Process all value-of attributes in the clone
-		clone.Find("[value-of]").Each(func(i int, valueNode *goquery.Selection) {
-			valueOf, _ := valueNode.Attr("value-of")
-
-			// Extract last parts for comparison
-			valueOfLast := valueOf
-			if idx := strings.LastIndex(valueOf, "."); idx != -1 {
-				valueOfLast = valueOf[idx+1:]
-			}
-
-			repeatLast := repeatPath
-			if idx := strings.LastIndex(repeatPath, "."); idx != -1 {
-				repeatLast = repeatPath[idx+1:]
+		// Process the content directives of the clone and its descendants
+		clone.Find(contentSelector).AddSelection(clone.Filter(contentSelector)).Each(func(i int, valueNode *goquery.Selection) {
+			var attr, valueOf string
+			for _, attr = range contentAttrs {
+				var exists bool
+				if valueOf, exists = valueNode.Attr(attr); exists {
+					break
+				}
 			}
 
 			// If last parts match, use current item's value
-			if valueOfLast == repeatLast {
-				strval := getStringValue(item)
-				if strings.TrimSpace(strval) == "" {
+			path, pipes := splitPipeline(valueOf)
+			if lastPathPart(path) == lastPathPart(repeatPath) {
+				value := resolvePipeline(valueOf, func(string) types.CVBase { return item })
+				if value == nil || strings.TrimSpace(getStringValue(value)) == "" {
 					ignore = true
 				}
-				url := getURL(item)
-				if url != "" {
-					valueNode.SetHtml(fmt.Sprintf(`<a href="%s">%s</a>`, url, strval))
-				} else {
-					valueNode.SetHtml(strval)
+				if value != nil {
+					setContent(valueNode, value, attrFormat(attr), warn)
 				}
-				valueNode.RemoveAttr("value-of")
-			} else if strings.HasPrefix(valueOf, repeatPath+".") {
-				// Make path relative to current item
-				propertyPath := valueOf[len(repeatPath)+1:]
-				valueNode.SetAttr("value-of", propertyPath)
+				valueNode.RemoveAttr(attr)
+			} else if strings.HasPrefix(path, repeatPath+".") {
+				// Make path relative to current item, keeping its formatters
+				propertyPath := path[len(repeatPath)+1:]
+				valueNode.SetAttr(attr, strings.TrimSpace(propertyPath+" "+pipes))
+			}
+		})
+
+		// Bind attr-* directives that belong to this item; nested repeat-for
+		// elements bind their own when they are expanded
+		clone.Find("*").AddSelection(clone).Each(func(i int, attrNode *goquery.Selection) {
+			if insideNestedRepeat(attrNode, clone) {
+				return
 			}
+			bindAttributes(attrNode, func(path string) types.CVBase {
+				if lastPathPart(path) == lastPathPart(repeatPath) {
+					return item
+				}
+				if strings.HasPrefix(path, repeatPath+".") {
+					path = path[len(repeatPath)+1:]
+				}
+				return getCVBaseFromPath(scope, path)
+			}, warn)
 		})
 
 		// Process the clone with current item as context
-		processNode(clone, item)
+		processNode(clone, scope, warn)
 
 		// Insert clone into parent
 		cloneHTML := getOuterHTML(clone)
@@ -257,6 +383,14 @@ func getCVBaseFromPath(data types.CVBase, path string) types.CVBase {
 	return current
 }
 
+// lastPathPart returns the segment after the final dot of a path
+func lastPathPart(path string) string {
+	if idx := strings.LastIndex(path, "."); idx != -1 {
+		return path[idx+1:]
+	}
+	return path
+}
+
 // getValueFromPath is a wrapper for getCVBaseFromPath
 func getValueFromPath(data types.CVBase, path string) types.CVBase {
 	return getCVBaseFromPath(data, path)
@@ -277,11 +411,37 @@ func getStringValue(cv types.CVBase) string {
 			parts = append(parts, getStringValue(item))
 		}
 		return strings.Join(parts, ", ")
+	case types.CVForgeMap:
+		return mapString(v, ", ")
 	default:
 		return ""
 	}
 }
 
+// mapString writes m as "key: value" pairs in the order they were written,
+// joined with sep. Meta keys, loop variables and empty values are left out.
+func mapString(m types.CVForgeMap, sep string) string {
+	var parts []string
+	for _, key := range mapContentKeys(m) {
+		if value := getStringValue(m.Value[key]); strings.TrimSpace(value) != "" {
+			parts = append(parts, key+": "+value)
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// mapContentKeys returns the keys of m that hold content rather than
+// configure it
+func mapContentKeys(m types.CVForgeMap) []string {
+	var keys []string
+	for _, key := range m.OrderedKeys() {
+		if !strings.HasPrefix(key, "$") && !slices.Contains(types.MetaKeys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
 // getURL extracts URL from CVTagInfo
 func getURL(cv types.CVBase) string {
 	if cv == nil {
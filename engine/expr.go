@@ -0,0 +1,571 @@
+package engine
+
+import (
+	"cvforge/types"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Expressions for the if directive:
+//
+//	if="endDate == 'Present'"
+//	if="len(experience) > 3 && !$last"
+//	if="'go' in tags"
+//
+// They can only read data. Paths resolve against the current context the
+// way value-of paths do, and evaluation never fails: a missing value is
+// null, which is false and equal only to null.
+
+// exprNode is a parsed expression. eval returns nil, a bool, a float64, a
+// string, a slice or map of data, or the []string tags of a value.
+type exprNode interface {
+	eval(context types.CVBase) any
+}
+
+type exprLiteral struct{ value any }
+
+type exprPath string
+
+type exprNot struct{ operand exprNode }
+
+type exprLogic struct {
+	and         bool
+	left, right exprNode
+}
+
+type exprCompare struct {
+	op          string
+	left, right exprNode
+}
+
+type exprCall struct {
+	fn   exprFunc
+	args []exprNode
+}
+
+// exprFunc is a function expressions can call
+type exprFunc struct {
+	arity int
+	call  func(args []any) any
+}
+
+// exprFuncs are the functions available to expressions
+var exprFuncs = map[string]exprFunc{
+	"len":   {1, func(args []any) any { return exprLen(args[0]) }},
+	"lower": {1, func(args []any) any { return strings.ToLower(exprText(args[0])) }},
+	"upper": {1, func(args []any) any { return strings.ToUpper(exprText(args[0])) }},
+	"trim":  {1, func(args []any) any { return strings.TrimSpace(exprText(args[0])) }},
+}
+
+func (l exprLiteral) eval(types.CVBase) any { return l.value }
+
+// eval resolves the path. A path ending in tags yields the parsed tags of
+// the value it belongs to, so 'go' in tags matches however they were written.
+func (p exprPath) eval(context types.CVBase) any {
+	path := string(p)
+	if lastPathPart(path) == "tags" {
+		owner := context
+		if idx := strings.LastIndex(path, "."); idx != -1 {
+			owner = getCVBaseFromPath(context, path[:idx])
+		}
+		switch v := owner.(type) {
+		case types.CVForgeString:
+			return v.Tags
+		case types.CVForgeSlice:
+			return v.Tags
+		case types.CVForgeMap:
+			return v.Tags
+		}
+	}
+	return exprValue(getCVBaseFromPath(context, path))
+}
+
+func (n exprNot) eval(context types.CVBase) any { return !exprTruthy(n.operand.eval(context)) }
+
+func (l exprLogic) eval(context types.CVBase) any {
+	left := exprTruthy(l.left.eval(context))
+	if left != l.and {
+		// false && ..., true || ...
+		return left
+	}
+	return exprTruthy(l.right.eval(context))
+}
+
+func (c exprCompare) eval(context types.CVBase) any {
+	left, right := c.left.eval(context), c.right.eval(context)
+	switch c.op {
+	case "==":
+		return exprEqual(left, right)
+	case "!=":
+		return !exprEqual(left, right)
+	case "in":
+		return exprContains(right, left)
+	case "not in":
+		return !exprContains(right, left)
+	}
+
+	if left == nil || right == nil {
+		return false
+	}
+	cmp := exprOrder(left, right)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+func (c exprCall) eval(context types.CVBase) any {
+	args := make([]any, len(c.args))
+	for i, arg := range c.args {
+		args[i] = arg.eval(context)
+	}
+	return c.fn.call(args)
+}
+
+// exprValue unwraps strings so they compare as text
+func exprValue(cv types.CVBase) any {
+	switch v := cv.(type) {
+	case nil:
+		return nil
+	case types.CVForgeString:
+		return v.Value
+	case *types.CVForgeString:
+		return v.Value
+	}
+	return cv
+}
+
+// exprTruthy applies the if-exists rules: null, false, zero and empty
+// strings, lists and maps are false
+func exprTruthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case types.CVForgeSlice:
+		return len(v.Value) > 0
+	case types.CVForgeMap:
+		return len(v.Value) > 0
+	case []string:
+		return len(v) > 0
+	}
+	return true
+}
+
+func exprText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		return strings.Join(v, ", ")
+	case types.CVBase:
+		return getStringValue(v)
+	}
+	return ""
+}
+
+func exprNumber(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func exprLen(v any) float64 {
+	switch v := v.(type) {
+	case string:
+		return float64(utf8.RuneCountInString(v))
+	case types.CVForgeSlice:
+		return float64(len(v.Value))
+	case types.CVForgeMap:
+		return float64(len(v.Value))
+	case []string:
+		return float64(len(v))
+	}
+	return 0
+}
+
+func exprEqual(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if x, ok := exprNumber(a); ok {
+		if y, ok := exprNumber(b); ok {
+			return x == y
+		}
+	}
+	return exprText(a) == exprText(b)
+}
+
+// exprOrder compares numbers by value and anything else the way sort-by
+// does, so dates such as 2022-06 and Present order naturally
+func exprOrder(a, b any) int {
+	if x, ok := exprNumber(a); ok {
+		if y, ok := exprNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return compareSortValues(parseSortText(exprText(a)), parseSortText(exprText(b)))
+}
+
+// exprContains reports whether needle is a tag, item or key of haystack, or
+// a substring of it when it is text
+func exprContains(haystack, needle any) bool {
+	switch h := haystack.(type) {
+	case []string:
+		return slices.Contains(h, strings.ToLower(strings.TrimSpace(exprText(needle))))
+	case types.CVForgeSlice:
+		return slices.ContainsFunc(h.Value, func(item types.CVBase) bool {
+			return exprEqual(exprValue(item), needle)
+		})
+	case types.CVForgeMap:
+		_, ok := h.Value[exprText(needle)]
+		return ok
+	case string:
+		return needle != nil && strings.Contains(h, exprText(needle))
+	}
+	return false
+}
+
+// exprPaths returns the data paths expr reads
+func exprPaths(expr exprNode) []string {
+	switch n := expr.(type) {
+	case exprPath:
+		return []string{string(n)}
+	case exprNot:
+		return exprPaths(n.operand)
+	case exprLogic:
+		return append(exprPaths(n.left), exprPaths(n.right)...)
+	case exprCompare:
+		return append(exprPaths(n.left), exprPaths(n.right)...)
+	case exprCall:
+		var paths []string
+		for _, arg := range n.args {
+			paths = append(paths, exprPaths(arg)...)
+		}
+		return paths
+	}
+	return nil
+}
+
+// evalCondition parses expr and evaluates it against context
+func evalCondition(expr string, context types.CVBase) (bool, error) {
+	node, err := parseExpr(expr)
+	if err != nil {
+		return false, err
+	}
+	return exprTruthy(node.eval(context)), nil
+}
+
+// exprOperators are the characters only an expression, never a data path,
+// contains
+const exprOperators = "()=!<>'\"&|"
+
+// checkPathSyntax reports a data path written as an expression, such as
+// len(jobs) or a comparison. Only if evaluates expressions; elsewhere they
+// would silently resolve to nothing.
+func checkPathSyntax(path string) error {
+	path = strings.TrimSpace(path)
+	node, err := parseExpr(path)
+	if err != nil {
+		if strings.ContainsAny(path, exprOperators) {
+			return err
+		}
+		// Keys may hold spaces
+		return nil
+	}
+	switch node.(type) {
+	case exprPath:
+		return nil
+	case exprLiteral:
+		if !strings.ContainsAny(path, exprOperators) {
+			return nil
+		}
+	}
+	return fmt.Errorf("expression %q can only be used with if", path)
+}
+
+// parseExpr parses an expression. Conditions are combined with && (and),
+// || (or) and ! (not), grouped with parentheses; && binds tighter than ||.
+// A comparison is ==, !=, <, <=, >, >=, in or not in between two operands:
+// paths, 'quoted' or "quoted" strings, numbers, true, false, null or calls
+// to len, lower, upper and trim.
+func parseExpr(s string) (exprNode, error) {
+	p := &exprParser{src: s}
+	if p.peek() == 0 {
+		return nil, p.errorf("empty expression")
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if c := p.peek(); c != 0 {
+		return nil, p.errorf("unexpected %q", c)
+	}
+	return expr, nil
+}
+
+type exprParser struct {
+	src string
+	pos int
+}
+
+// exprStop ends paths, function names and keywords
+const exprStop = " \t\r\n()=!<>,'\"&|"
+
+// peek skips spaces and returns the next byte, or 0 at the end
+func (p *exprParser) peek() byte {
+	for p.pos < len(p.src) && strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos == len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("expression %q at column %d: %s", p.src, p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// accept consumes op if it comes next
+func (p *exprParser) accept(op string) bool {
+	p.peek()
+	if strings.HasPrefix(p.src[p.pos:], op) {
+		p.pos += len(op)
+		return true
+	}
+	return false
+}
+
+// acceptWord consumes the keyword word if it comes next as a whole word
+func (p *exprParser) acceptWord(word string) bool {
+	p.peek()
+	end := p.pos + len(word)
+	if !strings.HasPrefix(p.src[p.pos:], word) || (end < len(p.src) && !strings.ContainsRune(exprStop, rune(p.src[end]))) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") || p.acceptWord("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = exprLogic{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") || p.acceptWord("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = exprLogic{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.peek() == '!' && !strings.HasPrefix(p.src[p.pos:], "!=") {
+		p.pos++
+	} else if !p.acceptWord("not") {
+		return p.parseCompare()
+	}
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return exprNot{operand}, nil
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	var op string
+	for _, candidate := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(candidate) {
+			op = candidate
+			break
+		}
+	}
+	switch {
+	case op != "":
+	case p.acceptWord("in"):
+		op = "in"
+	case p.acceptWord("not"):
+		if !p.acceptWord("in") {
+			return nil, p.errorf("expected in after not")
+		}
+		op = "not in"
+	case p.peek() == '=':
+		return nil, p.errorf("use == to compare")
+	default:
+		return left, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return exprCompare{op: op, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseOperand() (exprNode, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, p.errorf("expected a value")
+	case c == '(':
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.errorf("missing )")
+		}
+		p.pos++
+		return expr, nil
+	case c == '\'' || c == '"':
+		return p.parseString(c)
+	case c >= '0' && c <= '9' || c == '-' && p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		text := p.src[start:p.pos]
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number %q", text)
+		}
+		return exprLiteral{n}, nil
+	case strings.ContainsRune(exprStop, rune(c)):
+		return nil, p.errorf("expected a value, found %q", c)
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(exprStop, rune(p.src[p.pos])) {
+		p.pos++
+	}
+	word := p.src[start:p.pos]
+	switch word {
+	case "true", "false":
+		return exprLiteral{word == "true"}, nil
+	case "null", "nil":
+		return exprLiteral{nil}, nil
+	case "and", "or", "not", "in":
+		p.pos = start
+		return nil, p.errorf("expected a value, found %q", word)
+	}
+
+	if p.peek() == '(' {
+		fn, ok := exprFuncs[word]
+		if !ok {
+			p.pos = start
+			return nil, p.errorf("unknown function %q", word)
+		}
+		return p.parseCall(word, fn)
+	}
+	for _, part := range strings.Split(word, ".") {
+		if part == "" {
+			p.pos = start
+			return nil, p.errorf("malformed path %q", word)
+		}
+	}
+	return exprPath(word), nil
+}
+
+func (p *exprParser) parseString(quote byte) (exprNode, error) {
+	start := p.pos
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return exprLiteral{sb.String()}, nil
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos++
+			c = p.src[p.pos]
+		}
+		sb.WriteByte(c)
+		p.pos++
+	}
+	p.pos = start
+	return nil, p.errorf("unterminated string")
+}
+
+func (p *exprParser) parseCall(name string, fn exprFunc) (exprNode, error) {
+	p.pos++ // (
+	call := exprCall{fn: fn}
+	if p.peek() != ')' {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+	}
+	if p.peek() != ')' {
+		return nil, p.errorf("missing ) after arguments to %s", name)
+	}
+	p.pos++
+	if len(call.args) != fn.arity {
+		return nil, p.errorf("%s takes %d argument, got %d", name, fn.arity, len(call.args))
+	}
+	return call, nil
+}
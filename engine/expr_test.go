@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"cvforge/types"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// exprString prints a parsed expression with every operation in
+// parentheses, to show how it was grouped
+func exprString(n exprNode) string {
+	switch n := n.(type) {
+	case exprLiteral:
+		if s, ok := n.value.(string); ok {
+			return fmt.Sprintf("%q", s)
+		}
+		return fmt.Sprint(n.value)
+	case exprPath:
+		return string(n)
+	case exprNot:
+		return "!" + exprString(n.operand)
+	case exprLogic:
+		op := "||"
+		if n.and {
+			op = "&&"
+		}
+		return "(" + exprString(n.left) + " " + op + " " + exprString(n.right) + ")"
+	case exprCompare:
+		return "(" + exprString(n.left) + " " + n.op + " " + exprString(n.right) + ")"
+	case exprCall:
+		args := make([]string, len(n.args))
+		for i, arg := range n.args {
+			args[i] = exprString(arg)
+		}
+		return "call(" + strings.Join(args, ", ") + ")"
+	}
+	return "?"
+}
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"a", "a"},
+		{"a.b.$key", "a.b.$key"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c", "((a && b) || c)"},
+		{"a || b || c", "((a || b) || c)"},
+		{"(a || b) && c", "((a || b) && c)"},
+		{"!a && b", "(!a && b)"},
+		{"!(a && b)", "!(a && b)"},
+		{"!!a", "!!a"},
+		{"a and b or not c", "((a && b) || !c)"},
+		{"a == 1 && b != 'x'", "((a == 1) && (b != \"x\"))"},
+		{"!a == b", "!(a == b)"},
+		{"a<=b", "(a <= b)"},
+		{"a >= -2.5", "(a >= -2.5)"},
+		{"'go' in tags", `("go" in tags)`},
+		{"'go' not in tags", `("go" not in tags)`},
+		{"not 'go' in tags", `!("go" in tags)`},
+		{"index == 1", "(index == 1)"},
+		{"android", "android"},
+		{"notes in order", "(notes in order)"},
+		{`"a \"b\"" == 'it\'s'`, `("a \"b\"" == "it's")`},
+		{`'a && b || c'`, `"a && b || c"`},
+		{"''", `""`},
+		{"true != false", "(true != false)"},
+		{"a == null", "(a == <nil>)"},
+		{"a == nil", "(a == <nil>)"},
+		{"len(a) > 3", "(call(a) > 3)"},
+		{"lower(trim(a)) == 'x'", `(call(call(a)) == "x")`},
+		{"len(a || b)", "call((a || b))"},
+		{"  a\t==\n1  ", "(a == 1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			node, err := parseExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("parseExpr(%q): %v", tt.expr, err)
+			}
+			if got := exprString(node); got != tt.want {
+				t.Errorf("parseExpr(%q) = %s, want %s", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "column 1: empty expression"},
+		{"   ", "column 4: empty expression"},
+		{"a ==", "column 5: expected a value"},
+		{"a = b", "column 3: use == to compare"},
+		{"a == = b", `column 6: expected a value, found '='`},
+		{"&& a", `column 1: expected a value, found '&'`},
+		{"a &&", "column 5: expected a value"},
+		{"a & b", `column 3: unexpected '&'`},
+		{"a b", `column 3: unexpected 'b'`},
+		{"(a", "column 3: missing )"},
+		{"a)", `column 2: unexpected ')'`},
+		{"()", `column 2: expected a value, found ')'`},
+		{"'abc", "column 1: unterminated string"},
+		{`a == "b`, "column 6: unterminated string"},
+		{"1.2.3", `column 1: invalid number "1.2.3"`},
+		{"a..b", `column 1: malformed path "a..b"`},
+		{"a.", `column 1: malformed path "a."`},
+		{"a not b", "column 7: expected in after not"},
+		{"a in", "column 5: expected a value"},
+		{"and", `column 1: expected a value, found "and"`},
+		{"a == in", `column 6: expected a value, found "in"`},
+		{"size(a)", `column 1: unknown function "size"`},
+		{"len(a", "column 6: missing ) after arguments to len"},
+		{"len(a, b)", "column 10: len takes 1 argument, got 2"},
+		{"len()", "column 6: len takes 1 argument, got 0"},
+		{"a == 1 ||", "column 10: expected a value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := parseExpr(tt.expr)
+			if err == nil {
+				t.Fatalf("parseExpr(%q) succeeded, want an error", tt.expr)
+			}
+			prefix := fmt.Sprintf("expression %q at ", tt.expr)
+			if got := err.Error(); got != prefix+tt.want {
+				t.Errorf("parseExpr(%q) error = %q, want %q", tt.expr, got, prefix+tt.want)
+			}
+		})
+	}
+}
+
+const exprData = `
+name: Ada
+title: ""
+count: "12"
+endDate: Present
+startDate: 2022-06
+skills: [Go, Rust]
+empty: []
+links:
+  github: https://github.com/ada
+job:
+  value: Lead
+  tags: [Go, Cloud]
+`
+
+func TestEvalCondition(t *testing.T) {
+	raw, err := types.DecodeYAML([]byte(exprData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := types.UnmarshalCVBase(raw, types.DefaultCVTagInfo())
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// Truthiness
+		{"name", true},
+		{"title", false},
+		{"missing", false},
+		{"empty", false},
+		{"skills", true},
+		{"0", false},
+		{"''", false},
+
+		// Comparisons
+		{"name == 'Ada'", true},
+		{"name == \"ada\"", false},
+		{"lower(name) == 'ada'", true},
+		{"name != 'Bob'", true},
+		{"count == 12", true},
+		{"count > 9", true},
+		{"count < '9'", false},
+		{"missing == null", true},
+		{"name == null", false},
+		{"missing != ''", true},
+		{"missing < 1", false},
+		{"missing >= 1", false},
+		{"endDate > startDate", true},
+		{"startDate >= '2022-01'", true},
+		{"startDate < 'Jan 2022'", false},
+
+		// in
+		{"'Go' in skills", true},
+		{"'C' in skills", false},
+		{"'C' not in skills", true},
+		{"'github' in links", true},
+		{"'Ad' in name", true},
+		{"null in name", false},
+		{"'go' in job.tags", true},
+		{"'GO' in job.tags", true},
+		{"'aws' in job.tags", false},
+		{"'Go' in missing", false},
+
+		// Functions
+		{"len(skills) == 2", true},
+		{"len(name) == 3", true},
+		{"len(links) == 1", true},
+		{"len(job.tags) == 2", true},
+		{"len(missing) == 0", true},
+		{"upper(trim(' a ')) == 'A'", true},
+
+		// Logic and precedence
+		{"name && skills", true},
+		{"name && title", false},
+		{"title || name", true},
+		{"title || name && missing", false},
+		{"(title || name) && skills", true},
+		{"!title", true},
+		{"not missing and name", true},
+		{"true || false && false", true},
+		{"!(true && false)", true},
+		{"!true || true", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := evalCondition(tt.expr, data)
+			if err != nil {
+				t.Fatalf("evalCondition(%q): %v", tt.expr, err)
+			}
+			if got != tt.want {
+				t.Errorf("evalCondition(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExprPaths(t *testing.T) {
+	node, err := parseExpr("len(a.b) > 1 && ('x' in c || !d) && 'e' == 'e'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(exprPaths(node), ","); got != "a.b,c,d" {
+		t.Errorf("exprPaths = %s, want a.b,c,d", got)
+	}
+}
@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"cvforge/types"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FitPages renders data as a PDF of at most opts.MaxPages pages. When the
+// full document is longer, list items are dropped in the order
+// types.ListItems gives, lowest priority first, and the document is rendered
+// again. The fewest items that make it fit are found by binary search. It
+// returns the PDF and the items that were dropped.
+func FitPages(ctx context.Context, templatePath string, data types.CVBase, opts RenderOptions) ([]byte, []types.ListItem, error) {
+	if opts.MaxPages < 1 {
+		return nil, nil, fmt.Errorf("invalid page limit: %d", opts.MaxPages)
+	}
+	if opts.PDF != nil && opts.PDF.FitPages > 0 {
+		return nil, nil, fmt.Errorf("dropping items to fit a page limit cannot be combined with fit-pages scaling")
+	}
+	// Every attempt renders the same data; report each warning once
+	if warn := opts.Warn; warn != nil {
+		seen := make(map[string]bool)
+		opts.Warn = func(message string) {
+			if !seen[message] {
+				seen[message] = true
+				warn(message)
+			}
+		}
+	}
+	// Every attempt prints with the same browser
+	if opts.Renderer == nil {
+		renderer, err := NewPDFRenderer(1)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer renderer.Close()
+		opts.Renderer = renderer
+	}
+
+	attempt := func(drop int, candidates []types.ListItem) ([]byte, bool, error) {
+		paths := make([]string, drop)
+		for i := range paths {
+			paths[i] = candidates[i].Path
+		}
+		pdf, err := render(ctx, templatePath, types.DropItems(data, paths), OutputPDF, opts)
+		if err != nil {
+			return nil, false, err
+		}
+		pages, err := countPDFPages(pdf)
+		if err != nil {
+			return nil, false, err
+		}
+		return pdf, pages <= opts.MaxPages, nil
+	}
+
+	candidates := types.ListItems(data)
+	pdf, fits, err := attempt(0, candidates)
+	if err != nil || fits {
+		return pdf, nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil, fmt.Errorf("document does not fit in %d pages and has no list items to drop", opts.MaxPages)
+	}
+
+	best, fits, err := attempt(len(candidates), candidates)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !fits {
+		return nil, nil, fmt.Errorf("document does not fit in %d pages even without its %d list items", opts.MaxPages, len(candidates))
+	}
+
+	// Find the fewest drops that fit; lo never fits and hi always does
+	lo, hi := 0, len(candidates)
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		pdf, fits, err := attempt(mid, candidates)
+		if err != nil {
+			return nil, nil, err
+		}
+		if fits {
+			hi, best = mid, pdf
+		} else {
+			lo = mid
+		}
+	}
+	return best, candidates[:hi], nil
+}
+
+var (
+	pdfRoot      = regexp.MustCompile(`/Root\s+(\d+)\s+\d+\s+R`)
+	pdfPagesRef  = regexp.MustCompile(`/Pages\s+(\d+)\s+\d+\s+R`)
+	pdfCount     = regexp.MustCompile(`/Count\s+(\d+)`)
+	pdfStream    = regexp.MustCompile(`obj\s*<<((?:[^<>]|<<[^<>]*>>)*)>>\s*stream\r?\n`)
+	pdfStreamKey = regexp.MustCompile(`/(Type|N|First)\s*/?(\w+)`)
+)
+
+// countPDFPages returns the page count the PDF's page tree records: the
+// /Count of the /Pages node the document catalog points to
+func countPDFPages(pdf []byte) (int, error) {
+	if !bytes.HasPrefix(pdf, []byte("%PDF")) {
+		return 0, fmt.Errorf("output is not a PDF")
+	}
+	// The last trailer wins when the file has incremental updates
+	roots := pdfRoot.FindAllSubmatch(pdf, -1)
+	if len(roots) == 0 {
+		return 0, fmt.Errorf("PDF has no document catalog")
+	}
+	catalog, err := pdfObject(pdf, string(roots[len(roots)-1][1]))
+	if err != nil {
+		return 0, err
+	}
+	ref := pdfPagesRef.FindSubmatch(catalog)
+	if ref == nil {
+		return 0, fmt.Errorf("PDF document catalog has no page tree")
+	}
+	pages, err := pdfObject(pdf, string(ref[1]))
+	if err != nil {
+		return 0, err
+	}
+	count := pdfCount.FindSubmatch(pages)
+	if count == nil {
+		return 0, fmt.Errorf("PDF page tree has no page count")
+	}
+	return strconv.Atoi(string(count[1]))
+}
+
+// pdfObject returns the body of the PDF object with the given number,
+// looking in compressed object streams when it is not in the file itself
+func pdfObject(pdf []byte, num string) ([]byte, error) {
+	header := regexp.MustCompile(`(?:^|[^0-9])` + num + `\s+\d+\s+obj\b`)
+	if locs := header.FindAllIndex(pdf, -1); len(locs) > 0 {
+		body := pdf[locs[len(locs)-1][1]:]
+		if end := bytes.Index(body, []byte("endobj")); end >= 0 {
+			body = body[:end]
+		}
+		return body, nil
+	}
+
+	for _, loc := range pdfStream.FindAllSubmatchIndex(pdf, -1) {
+		dict := make(map[string]string)
+		for _, kv := range pdfStreamKey.FindAllSubmatch(pdf[loc[2]:loc[3]], -1) {
+			dict[string(kv[1])] = string(kv[2])
+		}
+		if dict["Type"] != "ObjStm" {
+			continue
+		}
+		n, _ := strconv.Atoi(dict["N"])
+		first, _ := strconv.Atoi(dict["First"])
+		zr, err := zlib.NewReader(bytes.NewReader(pdf[loc[1]:]))
+		if err != nil {
+			continue
+		}
+		data, _ := io.ReadAll(zr)
+		if first > len(data) {
+			continue
+		}
+		// The stream starts with n pairs of object number and offset
+		index := strings.Fields(string(data[:first]))
+		for i := 0; i+1 < len(index) && i < 2*n; i += 2 {
+			if index[i] != num {
+				continue
+			}
+			start, _ := strconv.Atoi(index[i+1])
+			end := len(data) - first
+			if i+3 < len(index) {
+				end, _ = strconv.Atoi(index[i+3])
+			}
+			if start < 0 || start > end || first+end > len(data) {
+				break
+			}
+			return data[first+start : first+end], nil
+		}
+	}
+	return nil, fmt.Errorf("PDF object %s not found", num)
+}
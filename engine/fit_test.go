@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCountPDFPages(t *testing.T) {
+	tests := []struct {
+		file string
+		want int
+	}{
+		// A nested page tree that an incremental update cut to two pages
+		{"updated.pdf", 2},
+		// Catalog and page tree inside a compressed object stream
+		{"objstm.pdf", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			pdf, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := countPDFPages(pdf)
+			if err != nil {
+				t.Fatalf("countPDFPages: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("countPDFPages = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountPDFPagesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		pdf  string
+		want string
+	}{
+		{"not a pdf", "<html></html>", "output is not a PDF"},
+		{"no catalog", "%PDF-1.4\n1 0 obj\n<< >>\nendobj\n", "PDF has no document catalog"},
+		{"missing catalog object", "%PDF-1.4\ntrailer\n<< /Root 9 0 R >>\n", "PDF object 9 not found"},
+		{"no page count", "%PDF-1.4\n1 0 obj\n<< /Pages 2 0 R >>\nendobj\n2 0 obj\n<< /Type /Pages >>\nendobj\ntrailer\n<< /Root 1 0 R >>\n", "PDF page tree has no page count"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := countPDFPages([]byte(tt.pdf))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("countPDFPages error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -3,128 +3,193 @@ package engine
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
-	"net/http/httptest"
+	"path"
 
 	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
 )
 
-func GeneratePDF(htmlContent string) ([]byte, error) {
-
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		fmt.Fprint(w, htmlContent)
-	}))
-	defer ts.Close()
-
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.NoSandbox,
-		chromedp.Headless,
-		chromedp.DisableGPU,
-		chromedp.NoFirstRun,
-		chromedp.NoDefaultBrowserCheck,
-	)
-
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancel()
-
-
-	ctx, cancel := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf))
-	defer cancel()
-
-
-	var pdfBuffer []byte
-
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(ts.URL),
-		chromedp.WaitReady("body"),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			var err error
-			pdfBuffer, _, err = page.PrintToPDF().
-				WithPrintBackground(true).
-				WithPaperWidth(8.27).          
-				WithPaperHeight(11.69).         
-				WithMarginTop(0.39).            
-				WithMarginBottom(0.39).         
-				WithMarginLeft(0.39).          
-				WithMarginRight(0.39).         
-				WithDisplayHeaderFooter(false). 
-				WithPreferCSSPageSize(true).   
-				Do(ctx)
-			return err
-		}),
-	)
+// GeneratePDF prints htmlContent to an A4 PDF. Relative URLs in the document
+// (stylesheets, images, fonts) are resolved against assetRoots in order.
+// It starts a browser for this call only; use a PDFRenderer for batches.
+func GeneratePDF(htmlContent string, assetRoots ...string) ([]byte, error) {
+	opts := DefaultPDFOptions()
+	opts.PreferCSSPageSize = true
+	return GeneratePDFWithOptions(htmlContent, opts, assetRoots...)
+}
 
+// GeneratePDFWithOptions is like GeneratePDF but prints with the given options.
+func GeneratePDFWithOptions(htmlContent string, opts PDFOptions, assetRoots ...string) ([]byte, error) {
+	renderer, err := NewPDFRenderer(1)
 	if err != nil {
-		return nil, fmt.Errorf("chromedp ile PDF oluşturma başarısız: %w", err)
+		return nil, err
 	}
+	defer renderer.Close()
 
-	return pdfBuffer, nil
+	return renderer.Generate(context.Background(), htmlContent, opts, assetRoots...)
 }
 
-func GeneratePDFWithOptions(htmlContent string, opts PDFOptions) ([]byte, error) {
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		fmt.Fprint(w, htmlContent)
-	}))
-	defer ts.Close()
-
-	allocOpts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.NoSandbox,
-		chromedp.Headless,
-		chromedp.DisableGPU,
-	)
-
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), allocOpts...)
-	defer cancel()
-
-	ctx, cancel := chromedp.NewContext(allocCtx)
-	defer cancel()
-
-	var pdfBuffer []byte
-
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(ts.URL),
-		chromedp.WaitReady("body"),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			printer := page.PrintToPDF().
-				WithPrintBackground(opts.PrintBackground).
-				WithPaperWidth(opts.PaperWidth).
-				WithPaperHeight(opts.PaperHeight).
-				WithMarginTop(opts.MarginTop).
-				WithMarginBottom(opts.MarginBottom).
-				WithMarginLeft(opts.MarginLeft).
-				WithMarginRight(opts.MarginRight).
-				WithDisplayHeaderFooter(opts.DisplayHeaderFooter).
-				WithPreferCSSPageSize(opts.PreferCSSPageSize)
-
-			if opts.Landscape {
-				printer = printer.WithLandscape(true)
-			}
-
-			if opts.HeaderTemplate != "" {
-				printer = printer.WithHeaderTemplate(opts.HeaderTemplate)
-			}
-
-			if opts.FooterTemplate != "" {
-				printer = printer.WithFooterTemplate(opts.FooterTemplate)
-			}
-
-			var err error
-			pdfBuffer, _, err = printer.Do(ctx)
-			return err
-		}),
-	)
+// printParams builds the Chrome print command for opts
+func printParams(opts PDFOptions) *page.PrintToPDFParams {
+	printer := page.PrintToPDF().
+		WithPrintBackground(opts.PrintBackground).
+		WithPaperWidth(opts.PaperWidth).
+		WithPaperHeight(opts.PaperHeight).
+		WithMarginTop(opts.MarginTop).
+		WithMarginBottom(opts.MarginBottom).
+		WithMarginLeft(opts.MarginLeft).
+		WithMarginRight(opts.MarginRight).
+		WithDisplayHeaderFooter(opts.DisplayHeaderFooter).
+		WithPreferCSSPageSize(opts.PreferCSSPageSize)
+
+	if opts.Landscape {
+		printer = printer.WithLandscape(true)
+	}
+
+	if opts.Scale > 0 {
+		printer = printer.WithScale(opts.Scale)
+	}
+
+	if opts.HeaderTemplate != "" {
+		printer = printer.WithHeaderTemplate(opts.HeaderTemplate)
+	}
+
+	if opts.FooterTemplate != "" {
+		printer = printer.WithFooterTemplate(opts.FooterTemplate)
+	}
+
+	return printer
+}
+
+// scaleTolerance is how close fitScale gets to the largest fitting scale
+const scaleTolerance = 0.01
+
+// printToFit prints the loaded page at the largest scale between
+// opts.MinScale and 1 at which it takes at most opts.FitPages pages; a
+// shorter document is printed at 1 and never scaled up. The page's
+// layout height gives a first guess and a binary search on the printed page
+// count settles it.
+func printToFit(ctx context.Context, opts PDFOptions) ([]byte, error) {
+	minScale := opts.MinScale
+	if minScale <= 0 {
+		minScale = DefaultMinScale
+	}
+	if minScale < 0.1 || minScale > 1 {
+		return nil, fmt.Errorf("invalid minimum scale %g (must be between 0.1 and 1)", minScale)
+	}
+
+	printAt := func(scale float64) ([]byte, int, error) {
+		scaled := opts
+		scaled.Scale = scale
+		pdf, _, err := printParams(scaled).Do(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		pages, err := countPDFPages(pdf)
+		return pdf, pages, err
+	}
+	return fitScale(printAt, opts.FitPages, minScale, estimateScale(ctx, opts))
+}
 
+// fitScale searches the scales between minScale and 1 for the largest at
+// which printAt gives at most maxPages pages, trying guess first when it
+// lies in between. A document that fits at 1 is returned as printed there.
+func fitScale(printAt func(scale float64) ([]byte, int, error), maxPages int, minScale, guess float64) ([]byte, error) {
+	pdf, pages, err := printAt(1)
+	if err != nil || pages <= maxPages {
+		return pdf, err
+	}
+	best, pages, err := printAt(minScale)
 	if err != nil {
-		return nil, fmt.Errorf("chromedp ile PDF oluşturma başarısız: %w", err)
+		return nil, err
+	}
+	if pages > maxPages {
+		return nil, fmt.Errorf("document takes %d pages even at the minimum scale %.2f, more than the %d allowed", pages, minScale, maxPages)
 	}
 
-	return pdfBuffer, nil
+	// lo always fits, hi never does
+	lo, hi := minScale, 1.0
+	for hi-lo > scaleTolerance {
+		mid := (lo + hi) / 2
+		if guess > lo && guess < hi {
+			mid, guess = guess, 0
+		}
+		pdf, pages, err := printAt(mid)
+		if err != nil {
+			return nil, err
+		}
+		if pages <= maxPages {
+			lo, best = mid, pdf
+		} else {
+			hi = mid
+		}
+	}
+	return best, nil
+}
+
+// estimateScale guesses the scale that fits the page's layout height into
+// opts.FitPages pages. It returns 0 when the height cannot be measured.
+func estimateScale(ctx context.Context, opts PDFOptions) float64 {
+	var height float64
+	if err := chromedp.Evaluate(`document.documentElement.scrollHeight`, &height).Do(ctx); err != nil || height <= 0 {
+		return 0
+	}
+	paperHeight := opts.PaperHeight
+	if opts.Landscape {
+		paperHeight = opts.PaperWidth
+	}
+	// CSS pixels are 1/96 inch
+	printable := (paperHeight - opts.MarginTop - opts.MarginBottom) * 96
+	return float64(opts.FitPages) * printable / height
 }
 
+// pageHandler serves the rendered document at "/" and every other path from
+// the asset roots, so the page sees the same files it would when opened
+// from disk.
+type pageHandler struct {
+	html  string
+	roots []string
+}
+
+func (h pageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, h.html)
+		return
+	}
+	AssetHandler(h.roots...).ServeHTTP(w, r)
+}
+
+// AssetHandler serves each request from the first of roots that contains
+// the requested file. Directories are never listed.
+func AssetHandler(roots ...string) http.Handler {
+	return assetHandler(roots)
+}
+
+type assetHandler []string
+
+func (roots assetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean(r.URL.Path)
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		f, err := http.Dir(root).Open(name)
+		if err != nil {
+			continue
+		}
+		stat, err := f.Stat()
+		if err != nil || stat.IsDir() {
+			f.Close()
+			continue
+		}
+		http.ServeContent(w, r, stat.Name(), stat.ModTime(), f)
+		f.Close()
+		return
+	}
+	http.NotFound(w, r)
+}
 
 type PDFOptions struct {
 	// Paper dimensions (in inches)
@@ -141,10 +206,20 @@ type PDFOptions struct {
 	Landscape           bool
 	DisplayHeaderFooter bool
 	PreferCSSPageSize   bool
-	HeaderTemplate string
-	FooterTemplate string
+	HeaderTemplate      string
+	FooterTemplate      string
+
+	// Scale shrinks or enlarges the page content; 0 prints at 100%
+	Scale float64
+	// FitPages, when set, prints at the largest scale between MinScale and
+	// 1 at which the document takes at most this many pages
+	FitPages int
+	MinScale float64
 }
 
+// DefaultMinScale is the smallest scale FitPages shrinks to unless
+// MinScale is set
+const DefaultMinScale = 0.5
 
 func DefaultPDFOptions() PDFOptions {
 	return PDFOptions{
@@ -161,7 +236,6 @@ func DefaultPDFOptions() PDFOptions {
 	}
 }
 
-
 func LetterPDFOptions() PDFOptions {
 	return PDFOptions{
 		PaperWidth:          8.5,
@@ -175,4 +249,4 @@ func LetterPDFOptions() PDFOptions {
 		DisplayHeaderFooter: false,
 		PreferCSSPageSize:   false,
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+)
+
+// fakePrinter prints a document that is length pages long at scale 1 and
+// records the scales it was asked for
+type fakePrinter struct {
+	length float64
+	scales []float64
+}
+
+func (p *fakePrinter) printAt(scale float64) ([]byte, int, error) {
+	p.scales = append(p.scales, scale)
+	return []byte(strconv.FormatFloat(scale, 'f', -1, 64)), int(math.Ceil(p.length * scale)), nil
+}
+
+func TestFitScale(t *testing.T) {
+	tests := []struct {
+		name     string
+		length   float64
+		maxPages int
+		minScale float64
+		guess    float64
+		low      float64
+		high     float64
+	}{
+		// A shorter document is printed once at 1 and never scaled up
+		{"shorter", 1.2, 3, 0.5, 0, 1, 1},
+		{"exact", 2, 2, 0.5, 0, 1, 1},
+		{"shrunk", 2.5, 2, 0.5, 0, 0.8 - scaleTolerance, 0.8},
+		{"shrunk from guess", 2.5, 2, 0.5, 0.79, 0.8 - scaleTolerance, 0.8},
+		{"minimum scale", 4, 2, 0.5, 0, 0.5, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &fakePrinter{length: tt.length}
+			pdf, err := fitScale(p.printAt, tt.maxPages, tt.minScale, tt.guess)
+			if err != nil {
+				t.Fatalf("fitScale: %v", err)
+			}
+			scale, _ := strconv.ParseFloat(string(pdf), 64)
+			if scale < tt.low || scale > tt.high {
+				t.Errorf("fitScale printed at %g, want between %g and %g", scale, tt.low, tt.high)
+			}
+			if tt.length <= float64(tt.maxPages) && len(p.scales) != 1 {
+				t.Errorf("fitScale printed at %v, want a single print at 1", p.scales)
+			}
+		})
+	}
+}
+
+func TestFitScaleOverflow(t *testing.T) {
+	p := &fakePrinter{length: 5}
+	pdf, err := fitScale(p.printAt, 2, 0.5, 0)
+	want := "document takes 3 pages even at the minimum scale 0.50, more than the 2 allowed"
+	if err == nil || err.Error() != want {
+		t.Errorf("fitScale error = %v, want %q", err, want)
+	}
+	if pdf != nil {
+		t.Errorf("fitScale returned a PDF with the error")
+	}
+	if fmt.Sprint(p.scales) != "[1 0.5]" {
+		t.Errorf("fitScale printed at %v, want [1 0.5]", p.scales)
+	}
+}
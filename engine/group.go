@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"cvforge/types"
+	"strings"
+)
+
+// groupByAttr buckets repeat-for items by a field: group-by="company"
+const groupByAttr = "group-by"
+
+// groupEntries buckets entries by the value of field, in the order each
+// value is first seen. Every group becomes one entry keyed by that value,
+// whose item holds the group's items: a map when the entries came from a
+// map, so their keys survive, and a slice otherwise. Items without the
+// field share the group with an empty key.
+func groupEntries(entries []repeatEntry, field string, hasKeys bool) []repeatEntry {
+	var groups []repeatEntry
+	index := make(map[string]int)
+	for _, e := range entries {
+		scope := loopScope(e.item, loopPosition{key: e.key, hasKey: hasKeys, count: len(entries)})
+		key := strings.TrimSpace(getStringValue(getCVBaseFromPath(scope, field)))
+
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, repeatEntry{key: key, item: newGroup(hasKeys)})
+		}
+		switch g := groups[i].item.(type) {
+		case types.CVForgeMap:
+			g.Set(e.key, e.item)
+			groups[i].item = g
+		case types.CVForgeSlice:
+			g.Value = append(g.Value, e.item)
+			groups[i].item = g
+		}
+	}
+	return groups
+}
+
+func newGroup(hasKeys bool) types.CVBase {
+	if hasKeys {
+		return types.CVForgeMap{CVTagInfo: types.DefaultCVTagInfo(), Value: make(map[string]types.CVBase)}
+	}
+	return types.CVForgeSlice{CVTagInfo: types.DefaultCVTagInfo()}
+}
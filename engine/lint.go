@@ -0,0 +1,690 @@
+package engine
+
+import (
+	"cvforge/types"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Lint severities
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintIssue is a problem Lint found in a template or its data
+type LintIssue struct {
+	Line     int // template line; 0 for issues about the data
+	Severity string
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%d: %s: %s", i.Line, i.Severity, i.Message)
+}
+
+// directives lists every attribute the engine interprets
+var directives = []string{"value-of", "value-of-html", "value-md", "repeat-for", "if-exists", "if-missing", "if", "else", "if-tag", "sort-by", "order", "group-by", "limit", "offset", "join"}
+
+// minNearMissLength is the shortest attribute name checked for misspelled
+// directives; shorter names such as id, cx or as are close to everything
+const minNearMissLength = 4
+
+// voidElements never have children, so they are not pushed on the stack
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// foreignElements hold SVG or MathML, whose attributes are not checked
+// for misspelled directives
+var foreignElements = map[string]bool{"svg": true, "math": true}
+
+// Lint checks every directive in the template against data, resolving paths
+// the same way Render does. It reports paths that resolve to nothing,
+// malformed directives and data keys the template never uses.
+func Lint(templatePath string, data types.CVBase) ([]LintIssue, error) {
+	file, err := os.Open(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	l := &linter{
+		used:     make(map[string]bool),
+		consumed: make(map[string]bool),
+		tags:     data.GetEveryTag(),
+	}
+	root := &lintScope{items: []lintContext{{value: data}}}
+
+	type openElement struct {
+		name  string
+		scope *lintScope
+		// afterCondition is set while the last child element seen was an
+		// if-exists, if-missing or else branch that an else may follow
+		afterCondition bool
+		// foreign is set inside svg and math, whose attributes (cx, d,
+		// order) are never directives
+		foreign bool
+	}
+	var stack []openElement
+	rootAfterCondition := false
+	line := 1
+
+	tokenizer := html.NewTokenizer(file)
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tokenLine := line
+		line += strings.Count(string(tokenizer.Raw()), "\n")
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			scope, afterCondition := root, &rootAfterCondition
+			foreign := foreignElements[token.Data]
+			if len(stack) > 0 {
+				parent := &stack[len(stack)-1]
+				scope, afterCondition, foreign = parent.scope, &parent.afterCondition, foreign || parent.foreign
+			}
+			l.checkElse(token, tokenLine, afterCondition)
+			childScope := l.element(token, tokenLine, scope, foreign)
+			if tt == html.StartTagToken && !voidElements[token.Data] {
+				stack = append(stack, openElement{name: token.Data, scope: childScope, foreign: foreign})
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == string(name) {
+					stack = stack[:i]
+					break
+				}
+			}
+		}
+	}
+
+	l.reportUnused(data, "")
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		a, b := l.issues[i], l.issues[j]
+		if (a.Line == 0) != (b.Line == 0) {
+			return b.Line == 0
+		}
+		return a.Line < b.Line
+	})
+	return l.issues, nil
+}
+
+// lintScope is what paths resolve against at some point of the template:
+// the root data, or every item a repeat-for may produce
+type lintScope struct {
+	repeatPath string // "" for the root scope
+	items      []lintContext
+	parent     *lintScope
+}
+
+// lintContext is one candidate context with its data path, where slice
+// indices are written as "*"
+type lintContext struct {
+	value types.CVBase
+	path  string
+}
+
+type linter struct {
+	issues []LintIssue
+	// used holds data paths a directive resolved to; consumed holds paths
+	// rendered as a whole, which use everything below them
+	used     map[string]bool
+	consumed map[string]bool
+	// tags holds every tag found in the data, for checking if-tag
+	tags []string
+}
+
+func (l *linter) report(line int, severity, format string, args ...any) {
+	l.issues = append(l.issues, LintIssue{Line: line, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// element checks the directives of one start tag and returns the scope its
+// children are processed in. foreign is set for SVG and MathML elements.
+func (l *linter) element(token html.Token, line int, scope *lintScope, foreign bool) *lintScope {
+	attrs := make(map[string]string)
+	for _, attr := range token.Attr {
+		attrs[attr.Key] = attr.Val
+		if !foreign {
+			l.checkAttrName(attr.Key, line)
+		}
+	}
+
+	if path, ok := attrs[ifExistsAttr]; ok && l.checkPath(ifExistsAttr, path, line) {
+		if !l.resolveIfExists(scope, path) {
+			l.report(line, LintWarning, "if-exists %q is never true: no data found", path)
+		}
+	}
+	if path, ok := attrs[ifMissingAttr]; ok && l.checkPath(ifMissingAttr, path, line) {
+		l.resolveIfExists(scope, path)
+	}
+	_, isRepeat := attrs["repeat-for"]
+	if expr, ok := attrs[ifAttr]; ok && !isRepeat {
+		l.checkExpr(expr, scope, line)
+	}
+
+	if value, ok := attrs[ifTagAttr]; ok {
+		l.checkIfTag(value, line)
+	}
+
+	if _, ok := attrs[joinAttr]; ok && !slices.ContainsFunc(contentAttrs, func(attr string) bool {
+		_, ok := attrs[attr]
+		return ok
+	}) {
+		l.report(line, LintWarning, "join has no effect without value-of, value-of-html or value-md")
+	}
+	if !isRepeat {
+		for _, attr := range repeatModifiers {
+			if _, ok := attrs[attr]; ok {
+				l.report(line, LintWarning, "%s has no effect without repeat-for", attr)
+			}
+		}
+	}
+
+	childScope := scope
+	if path, ok := attrs["repeat-for"]; ok && l.checkPath("repeat-for", path, line) {
+		childScope = l.resolveRepeat(scope, path, line)
+		l.checkRepeatModifiers(attrs, path, childScope, line)
+		if groupBy, ok := attrs[groupByAttr]; ok && l.checkPath(groupByAttr, groupBy, line) {
+			childScope = l.groupRepeat(childScope, strings.TrimPrefix(strings.TrimSpace(groupBy), path+"."), line)
+		}
+		// if filters the items, so it resolves against each of them
+		if expr, ok := attrs[ifAttr]; ok {
+			l.checkExpr(expr, childScope, line)
+		}
+
+		// Render processes the repeated element itself against each item
+		for _, attr := range contentAttrs {
+			if expr, ok := attrs[attr]; ok {
+				if valueOf, optional := l.checkPipeline(attr, expr, line); l.checkPath(attr, valueOf, line) {
+					resolved := l.resolveDirect(childScope, valueOf, true)
+					l.expectResolved(childScope, attr, valueOf, resolved || optional, line)
+				}
+			}
+		}
+		l.checkBindings(attrs, childScope, line)
+		return childScope
+	}
+
+	for _, attr := range contentAttrs {
+		if expr, ok := attrs[attr]; ok {
+			if valueOf, optional := l.checkPipeline(attr, expr, line); l.checkPath(attr, valueOf, line) {
+				l.expectResolved(scope, attr, valueOf, l.resolveValueOf(scope, valueOf) || optional, line)
+			}
+		}
+	}
+	l.checkBindings(attrs, scope, line)
+	return childScope
+}
+
+// checkExpr reports if expressions that do not parse, and paths in them
+// that no data has. Missing data is not an error in an expression, so those
+// are warnings.
+func (l *linter) checkExpr(expr string, scope *lintScope, line int) {
+	node, err := parseExpr(expr)
+	if err != nil {
+		l.report(line, LintError, "if: %s", err)
+		return
+	}
+	for _, path := range exprPaths(node) {
+		if !l.resolveDirect(scope, path, false) && lastPathPart(path) != "tags" && len(scope.items) > 0 {
+			l.report(line, LintWarning, "if %q: %q does not resolve to any data", expr, path)
+		}
+	}
+}
+
+// checkElse reports else directives with no conditional sibling before
+// them, and records whether the next sibling may be an else
+func (l *linter) checkElse(token html.Token, line int, afterCondition *bool) {
+	var isElse, isCondition, isRepeat, hasIf bool
+	for _, attr := range token.Attr {
+		switch attr.Key {
+		case elseAttr:
+			isElse = true
+			if strings.TrimSpace(attr.Val) != "" {
+				l.report(line, LintWarning, "else takes no value, %q is ignored", attr.Val)
+			}
+		case ifExistsAttr, ifMissingAttr:
+			isCondition = true
+		case ifAttr:
+			hasIf = true
+		case "repeat-for":
+			isRepeat = true
+		}
+	}
+	// if on a repeat-for element filters its items and leaves the element
+	isCondition = isCondition || hasIf && !isRepeat
+	if isElse && !*afterCondition {
+		l.report(line, LintError, "else does not follow an element with if-exists, if-missing or if")
+	}
+	*afterCondition = isCondition
+}
+
+// checkRepeatModifiers validates sort-by, order, limit and offset on a
+// repeat-for element; sort-by resolves against each item like $key does
+func (l *linter) checkRepeatModifiers(attrs map[string]string, repeatPath string, childScope *lintScope, line int) {
+	if sortBy, ok := attrs[sortByAttr]; ok && l.checkPath(sortByAttr, sortBy, line) {
+		sortBy = strings.TrimPrefix(strings.TrimSpace(sortBy), repeatPath+".")
+		l.expectResolved(childScope, sortByAttr, sortBy, l.resolveDirect(childScope, sortBy, false), line)
+	}
+	if order, ok := attrs[orderAttr]; ok {
+		if _, err := parseOrder(order); err != nil {
+			l.report(line, LintError, "%s", err)
+		}
+	}
+	for _, attr := range []string{offsetAttr, limitAttr} {
+		if value, ok := attrs[attr]; ok {
+			if _, err := parseCount(attr, value); err != nil {
+				l.report(line, LintError, "%s", err)
+			}
+		}
+	}
+}
+
+// checkIfTag reports if-tag directives naming tags no data carries, which
+// can never be switched on
+func (l *linter) checkIfTag(value string, line int) {
+	tags := types.SplitTags(value)
+	if len(tags) == 0 {
+		l.report(line, LintError, "if-tag has no tags")
+		return
+	}
+	for _, tag := range tags {
+		if !slices.Contains(l.tags, tag) {
+			l.report(line, LintWarning, "if-tag %q: no data is tagged %q", value, tag)
+		}
+	}
+}
+
+// checkAttrName flags malformed attr-* names and near-misses of directives
+func (l *linter) checkAttrName(key string, line int) {
+	if key == attrPrefix {
+		l.report(line, LintError, "attr- directive is missing an attribute name")
+		return
+	}
+	if len(key) < minNearMissLength || strings.HasPrefix(key, attrPrefix) || slices.Contains(directives, key) {
+		return
+	}
+	for _, d := range directives {
+		// Short directives only tolerate one typo, and a different first
+		// letter means another word (border and order)
+		maxDistance := 2
+		if len(d) <= 5 {
+			maxDistance = 1
+		}
+		if len(d) >= minNearMissLength && key[0] == d[0] && editDistance(key, d) <= maxDistance {
+			l.report(line, LintWarning, "unknown attribute %q, did you mean %q?", key, d)
+			return
+		}
+	}
+}
+
+// checkPath reports empty paths, paths written as expressions and paths
+// with empty segments
+func (l *linter) checkPath(directive, path string, line int) bool {
+	if strings.TrimSpace(path) == "" {
+		l.report(line, LintError, "%s has an empty path", directive)
+		return false
+	}
+	if err := checkPathSyntax(path); err != nil {
+		l.report(line, LintError, "%s: %s", directive, err)
+		return false
+	}
+	for _, part := range strings.Split(path, ".") {
+		if strings.TrimSpace(part) == "" {
+			l.report(line, LintError, "%s has a malformed path %q", directive, path)
+			return false
+		}
+	}
+	return true
+}
+
+// checkPipeline reports formatters after a path that are unknown or reject
+// their arguments, and returns the path. optional is set when a default
+// formatter covers the path resolving to nothing.
+func (l *linter) checkPipeline(directive, expr string, line int) (path string, optional bool) {
+	path, pipes := splitPipeline(expr)
+	if pipes == "" {
+		return path, false
+	}
+	steps, err := parsePipeline(pipes)
+	if err != nil {
+		l.report(line, LintError, "%s %q: %s", directive, expr, err)
+		return path, false
+	}
+	if err := steps.check(); err != nil {
+		l.report(line, LintError, "%s %q: %s", directive, expr, err)
+	}
+	for _, step := range steps {
+		if step.name == "default" {
+			optional = true
+		}
+	}
+	return path, optional
+}
+
+func (l *linter) checkBindings(attrs map[string]string, scope *lintScope, line int) {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		if strings.HasPrefix(key, attrPrefix) && key != attrPrefix {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		paths, err := bindingPaths(attrs[key])
+		if err != nil {
+			l.report(line, LintError, "%s: %s", key, err)
+			continue
+		}
+		for _, expr := range paths {
+			if path, optional := l.checkPipeline(key, expr, line); l.checkPath(key, path, line) {
+				l.expectResolved(scope, key, path, l.resolveBinding(scope, path) || optional, line)
+			}
+		}
+	}
+}
+
+// bindingPaths returns the data paths an attr-* value refers to
+func bindingPaths(expr string) ([]string, error) {
+	expr = strings.TrimSpace(expr)
+	if !strings.Contains(expr, "{") {
+		return []string{expr}, nil
+	}
+	var paths []string
+	rest := expr
+	for {
+		start := strings.Index(rest, "{")
+		if start == -1 {
+			if strings.Contains(rest, "}") {
+				return nil, fmt.Errorf("unbalanced braces in %q", expr)
+			}
+			return paths, nil
+		}
+		end := strings.Index(rest[start:], "}")
+		if end == -1 {
+			return nil, fmt.Errorf("unbalanced braces in %q", expr)
+		}
+		paths = append(paths, strings.TrimSpace(rest[start+1:start+end]))
+		rest = rest[start+end+1:]
+	}
+}
+
+func (l *linter) expectResolved(scope *lintScope, directive, path string, resolved bool, line int) {
+	if resolved {
+		return
+	}
+	if len(scope.items) == 0 {
+		l.report(line, LintError, "%s %q cannot be resolved: repeat-for %q has no data", directive, path, scope.repeatPath)
+		return
+	}
+	l.report(line, LintError, "%s %q does not resolve to any data", directive, path)
+}
+
+// resolveDirect resolves path against each context of scope as processNode
+// does. When consume is set, a match uses everything below it.
+func (l *linter) resolveDirect(scope *lintScope, path string, consume bool) bool {
+	found := false
+	for _, ctx := range scope.items {
+		if getCVBaseFromPath(ctx.value, path) != nil {
+			found = true
+			l.markUsed(joinDataPath(ctx.path, path), consume)
+		}
+	}
+	return found
+}
+
+func (l *linter) resolveIfExists(scope *lintScope, path string) bool {
+	found := false
+	for _, ctx := range scope.items {
+		if getCVBaseFromPath(ctx.value, path) != nil {
+			l.markUsed(joinDataPath(ctx.path, path), false)
+		}
+		if checkIfExists(nil, ctx.value, path) {
+			found = true
+		}
+	}
+	return found
+}
+
+// resolveValueOf applies the rewriting each enclosing repeat-for performs on
+// value-of paths, outermost first, then resolves what is left
+func (l *linter) resolveValueOf(scope *lintScope, path string) bool {
+	var chain []*lintScope
+	for s := scope; s != nil && s.repeatPath != ""; s = s.parent {
+		chain = append([]*lintScope{s}, chain...)
+	}
+
+	for _, loop := range chain {
+		if lastPathPart(path) == lastPathPart(loop.repeatPath) {
+			// The loop fills this element with its current item
+			for _, ctx := range loop.items {
+				l.markUsed(ctx.path, true)
+			}
+			return len(loop.items) > 0
+		}
+		if strings.HasPrefix(path, loop.repeatPath+".") {
+			path = path[len(loop.repeatPath)+1:]
+		}
+	}
+	return l.resolveDirect(scope, path, true)
+}
+
+// resolveBinding resolves an attr-* path the way the nearest repeat-for
+// binds it
+func (l *linter) resolveBinding(scope *lintScope, path string) bool {
+	if scope.repeatPath != "" {
+		if lastPathPart(path) == lastPathPart(scope.repeatPath) {
+			for _, ctx := range scope.items {
+				l.markUsed(ctx.path, true)
+			}
+			return len(scope.items) > 0
+		}
+		if strings.HasPrefix(path, scope.repeatPath+".") {
+			path = path[len(scope.repeatPath)+1:]
+		}
+	}
+	return l.resolveDirect(scope, path, true)
+}
+
+// resolveRepeat builds the scope a repeat-for's clones are processed in,
+// collecting the items of the collection in every current context
+func (l *linter) resolveRepeat(scope *lintScope, path string, line int) *lintScope {
+	child := &lintScope{repeatPath: path, parent: scope}
+	resolved := false
+
+	for _, ctx := range scope.items {
+		value, valuePath := getValueFromPath(ctx.value, path), joinDataPath(ctx.path, path)
+		if value == nil && strings.Contains(path, ".") {
+			value, valuePath = getValueFromPath(ctx.value, lastPathPart(path)), joinDataPath(ctx.path, lastPathPart(path))
+		}
+		if value == nil {
+			continue
+		}
+		resolved = true
+		l.markUsed(valuePath, false)
+
+		switch v := value.(type) {
+		case types.CVForgeSlice:
+			for i, item := range v.Value {
+				pos := loopPosition{index: i, count: len(v.Value), parent: ctx.value}
+				child.items = append(child.items, lintContext{value: loopScope(item, pos), path: valuePath + ".*"})
+			}
+		case types.CVForgeMap:
+			keys := v.OrderedKeys()
+			for i, k := range keys {
+				pos := loopPosition{index: i, count: len(keys), key: k, hasKey: true, parent: ctx.value}
+				child.items = append(child.items, lintContext{value: loopScope(v.Value[k], pos), path: valuePath + "." + k})
+			}
+		case types.CVForgeString:
+			l.markUsed(valuePath, true)
+			parts := strings.Split(v.Value, ",")
+			for i, part := range parts {
+				pos := loopPosition{index: i, count: len(parts), parent: ctx.value}
+				child.items = append(child.items, lintContext{value: loopScope(types.CVForgeString{Value: strings.TrimSpace(part)}, pos), path: valuePath})
+			}
+		}
+	}
+
+	if !resolved {
+		if len(scope.items) == 0 {
+			l.report(line, LintError, "repeat-for %q cannot be resolved: repeat-for %q has no data", path, scope.repeatPath)
+		} else {
+			l.report(line, LintError, "repeat-for %q does not resolve to any data", path)
+		}
+	}
+	return child
+}
+
+// groupRepeat turns the item contexts of a repeat-for scope into the group
+// contexts group-by produces. Items of every enclosing context are grouped
+// together, which is enough to resolve the paths used inside the groups.
+func (l *linter) groupRepeat(scope *lintScope, field string, line int) *lintScope {
+	if !l.resolveDirect(scope, field, false) && len(scope.items) > 0 {
+		l.report(line, LintError, "group-by %q does not resolve to any data", field)
+	}
+
+	grouped := &lintScope{repeatPath: scope.repeatPath, parent: scope.parent}
+	var entries []repeatEntry
+	groupPaths := make(map[string]string)
+	hasKeys := false
+	for _, ctx := range scope.items {
+		m := ctx.value.(types.CVForgeMap)
+		e := repeatEntry{item: m.Value[loopValueVar]}
+		path := strings.TrimSuffix(ctx.path, ".*")
+		if key, ok := m.Value[loopKeyVar].(types.CVForgeString); ok {
+			e.key, hasKeys = key.Value, true
+			path = strings.TrimSuffix(ctx.path, "."+key.Value)
+		}
+		entries = append(entries, e)
+
+		// A group's items resolve below the collection of its first item
+		groupKey := strings.TrimSpace(getStringValue(getCVBaseFromPath(ctx.value, field)))
+		if _, ok := groupPaths[groupKey]; !ok {
+			groupPaths[groupKey] = path
+		}
+	}
+
+	groups := groupEntries(entries, field, hasKeys)
+	for i, g := range groups {
+		pos := loopPosition{index: i, count: len(groups), key: g.key, hasKey: true, group: true}
+		grouped.items = append(grouped.items, lintContext{value: loopScope(g.item, pos), path: groupPaths[g.key]})
+	}
+	return grouped
+}
+
+func (l *linter) markUsed(path string, consume bool) {
+	l.used[path] = true
+	if consume {
+		l.consumed[path] = true
+	}
+}
+
+// isUsed reports whether a directive touched path, something below it, or
+// rendered something above it as a whole
+func (l *linter) isUsed(path string) bool {
+	for used := range l.used {
+		if used == path || strings.HasPrefix(used, path+".") {
+			return true
+		}
+	}
+	for consumed := range l.consumed {
+		if consumed == "" || strings.HasPrefix(path, consumed+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// reportUnused warns about map keys no directive used. Slice items share a
+// "*" path, so a key counts as used if any item used it.
+func (l *linter) reportUnused(data types.CVBase, path string) {
+	switch v := data.(type) {
+	case types.CVForgeMap:
+		for _, k := range v.OrderedKeys() {
+			keyPath := joinDataPath(path, k)
+			if slices.Contains(types.MetaKeys, k) && path != "" {
+				continue
+			}
+			if !l.isUsed(keyPath) {
+				l.report(0, LintWarning, "data key %q is never used by the template", keyPath)
+				continue
+			}
+			l.reportUnused(v.Value[k], keyPath)
+		}
+	case types.CVForgeSlice:
+		seen := make(map[string]bool)
+		for _, item := range v.Value {
+			before := len(l.issues)
+			l.reportUnused(item, joinDataPath(path, "*"))
+			// Report each key once, not once per item
+			kept := l.issues[:before]
+			for _, issue := range l.issues[before:] {
+				if !seen[issue.Message] {
+					seen[issue.Message] = true
+					kept = append(kept, issue)
+				}
+			}
+			l.issues = kept
+		}
+	}
+}
+
+// joinDataPath appends path to base, writing slice indices as "*" and
+// dropping loop variables, which do not name data
+func joinDataPath(base, path string) string {
+	for _, part := range strings.Split(path, ".") {
+		switch {
+		case part == loopValueVar:
+			continue
+		case strings.HasPrefix(part, "$"):
+			return base
+		}
+		if _, err := strconv.Atoi(part); err == nil {
+			part = "*"
+		}
+		if base == "" {
+			base = part
+		} else {
+			base += "." + part
+		}
+	}
+	return base
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
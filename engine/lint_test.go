@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"cvforge/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintAttributeNames(t *testing.T) {
+	raw, err := types.DecodeYAML([]byte("name: Ada\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := types.UnmarshalCVBase(raw, types.DefaultCVTagInfo())
+
+	tests := []struct {
+		name     string
+		template string
+		want     string // "" when no attribute is reported
+	}{
+		{"svg attributes", `<svg><circle cx="1" cy="2" r="3"></circle><path d="M0"></path></svg>`, ""},
+		{"inside svg", `<svg><g><text limt="1">x</text></g></svg>`, ""},
+		{"math attributes", `<math><mi ordr="x">x</mi></math>`, ""},
+		{"after svg", `<svg></svg><p limt="1"></p>`, `warning: unknown attribute "limt", did you mean "limit"?`},
+		{"short attributes", `<link as="style" href="x.css"><p id="a" dir="rtl"></p>`, ""},
+		{"html attributes", `<table border="1"><tr><td><input list="x"></td></tr></table>`, ""},
+		{"misspelled directive", `<p valu-of="name"></p>`, `warning: unknown attribute "valu-of", did you mean "value-of"?`},
+		{"misspelled modifier", `<p sortby="name"></p>`, `warning: unknown attribute "sortby", did you mean "sort-by"?`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "template.html")
+			if err := os.WriteFile(path, []byte(`<p value-of="name"></p>`+tt.template), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			issues, err := Lint(path, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, issue := range issues {
+				if strings.Contains(issue.Message, "unknown attribute") {
+					got = append(got, issue.String())
+				}
+			}
+			var want []string
+			if tt.want != "" {
+				want = []string{"1: " + tt.want}
+			}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("issues = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLintRepeatForIf(t *testing.T) {
+	raw, err := types.DecodeYAML([]byte("jobs:\n  - company: A\n    end: Present\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := types.UnmarshalCVBase(raw, types.DefaultCVTagInfo())
+
+	path := filepath.Join(t.TempDir(), "template.html")
+	template := `<p repeat-for="jobs" if="end == 'Present' &amp;&amp; $index &lt; 3"><b value-of="company"></b></p>
+<p repeat-for="jobs" if="stat == 'x'"><b value-of="company"></b></p>
+<p else>none</p>`
+	if err := os.WriteFile(path, []byte(template), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	issues, err := Lint(path, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		`2: warning: if "stat == 'x'": "stat" does not resolve to any data`,
+		`3: error: else does not follow an element with if-exists, if-missing or if`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLintPathExpressions(t *testing.T) {
+	raw, err := types.DecodeYAML([]byte("jobs:\n  - company: A\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := types.UnmarshalCVBase(raw, types.DefaultCVTagInfo())
+
+	path := filepath.Join(t.TempDir(), "template.html")
+	template := `<p value-of="len(jobs)"></p>
+<p attr-title="{len(jobs) | default:'0'}"></p>
+<p if-exists="len(jobs) > 1"></p>
+<p repeat-for="jobs" if="len(company) > 0"><b value-of="company"></b></p>`
+	if err := os.WriteFile(path, []byte(template), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	issues, err := Lint(path, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		`1: error: value-of: expression "len(jobs)" can only be used with if`,
+		`2: error: attr-title: expression "len(jobs)" can only be used with if`,
+		`3: error: if-exists: expression "len(jobs) > 1" can only be used with if`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
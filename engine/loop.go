@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"cvforge/types"
+	"strconv"
+)
+
+// Loop variables exposed to repeat-for clones
+const (
+	loopKeyVar    = "$key"    // key of a map entry
+	loopValueVar  = "$value"  // the item itself
+	loopIndexVar  = "$index"  // position from 0
+	loopNumberVar = "$number" // position from 1
+	loopFirstVar  = "$first"  // "true" on the first item only
+	loopLastVar   = "$last"   // "true" on the last item only
+	loopCountVar  = "$count"  // number of items
+	loopParentVar = "$parent" // scope of the enclosing repeat-for
+	loopItemsVar  = "$items"  // items of a group-by group
+)
+
+// loopPosition is where an item sits in the collection a repeat-for
+// iterates
+type loopPosition struct {
+	index, count int
+	key          string
+	hasKey       bool
+	// group is set when the item is a group built by group-by
+	group bool
+	// parent is the context the repeat-for itself was processed in
+	parent types.CVBase
+}
+
+// loopScope returns the context a repeat-for clone is processed with: the
+// item's tags, its own fields when it is a map, and the loop variables.
+// $first and $last are only set when true so if-exists can test them.
+func loopScope(item types.CVBase, pos loopPosition) types.CVBase {
+	scope := types.CVForgeMap{Value: make(map[string]types.CVBase)}
+	switch v := item.(type) {
+	case types.CVForgeMap:
+		scope.CVTagInfo = v.CVTagInfo
+		for _, k := range v.OrderedKeys() {
+			scope.Set(k, v.Value[k])
+		}
+	case types.CVForgeString:
+		scope.CVTagInfo = v.CVTagInfo
+	case types.CVForgeSlice:
+		scope.CVTagInfo = v.CVTagInfo
+	}
+
+	if pos.hasKey {
+		scope.Set(loopKeyVar, loopString(pos.key))
+	}
+	scope.Set(loopValueVar, item)
+	if pos.group {
+		scope.Set(loopItemsVar, item)
+	}
+	scope.Set(loopIndexVar, loopString(strconv.Itoa(pos.index)))
+	scope.Set(loopNumberVar, loopString(strconv.Itoa(pos.index+1)))
+	scope.Set(loopCountVar, loopString(strconv.Itoa(pos.count)))
+	if pos.index == 0 {
+		scope.Set(loopFirstVar, loopString("true"))
+	}
+	if pos.index == pos.count-1 {
+		scope.Set(loopLastVar, loopString("true"))
+	}
+	if isLoopScope(pos.parent) {
+		scope.Set(loopParentVar, pos.parent)
+	}
+	return scope
+}
+
+// isLoopScope reports whether context was built by loopScope
+func isLoopScope(context types.CVBase) bool {
+	m, ok := context.(types.CVForgeMap)
+	if !ok {
+		return false
+	}
+	_, ok = m.Value[loopIndexVar]
+	return ok
+}
+
+func loopString(s string) types.CVForgeString {
+	return types.CVForgeString{CVTagInfo: types.DefaultCVTagInfo(), Value: s}
+}
@@ -0,0 +1,394 @@
+package engine
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	headingPattern   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	listItemPattern  = regexp.MustCompile(`^\s{0,3}([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	fencePattern     = regexp.MustCompile("^\\s{0,3}(```+|~~~+)")
+	autolinkPattern  = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*|[^\s<>@]+@[^\s<>@]+)>`)
+	markdownEscapeCh = "\\`*_{}[]()#+-.!<>|~\""
+)
+
+// renderMarkdown converts CommonMark-style text to HTML. It supports
+// paragraphs, hard line breaks, headings, lists, fenced code, emphasis,
+// code spans, links and autolinks. Raw HTML is escaped and links only
+// accept safe schemes, so the output is safe to insert.
+//
+// Text that is a single paragraph is returned without the <p> wrapper so
+// it can be inserted into inline elements and list items.
+func renderMarkdown(src string) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	var (
+		blocks    []string
+		paragraph []string
+		listTag   string
+		listItems []string
+	)
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, "<p>"+markdownLines(paragraph)+"</p>")
+			paragraph = nil
+		}
+	}
+	flushList := func() {
+		if listTag == "" {
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString("<" + listTag + ">")
+		for _, item := range listItems {
+			sb.WriteString("<li>" + markdownLines(strings.Split(item, "\n")) + "</li>")
+		}
+		sb.WriteString("</" + listTag + ">")
+		blocks = append(blocks, sb.String())
+		listTag, listItems = "", nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if fence := fencePattern.FindStringSubmatch(line); fence != nil {
+			flushParagraph()
+			flushList()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence[1]); i++ {
+				code = append(code, lines[i])
+			}
+			blocks = append(blocks, "<pre><code>"+html.EscapeString(strings.Join(code, "\n"))+"</code></pre>")
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			// A blank line ends a paragraph; a list goes on if the next
+			// line is another item, so loose lists stay one list
+			flushParagraph()
+		case headingPattern.MatchString(trimmed):
+			flushParagraph()
+			flushList()
+			m := headingPattern.FindStringSubmatch(trimmed)
+			tag := "h" + strconv.Itoa(len(m[1]))
+			blocks = append(blocks, "<"+tag+">"+markdownInline(m[2])+"</"+tag+">")
+		case listItemPattern.MatchString(line):
+			flushParagraph()
+			m := listItemPattern.FindStringSubmatch(line)
+			tag := "ul"
+			if m[1][0] >= '0' && m[1][0] <= '9' {
+				tag = "ol"
+			}
+			if tag != listTag {
+				flushList()
+				listTag = tag
+			}
+			listItems = append(listItems, m[2])
+		case listTag != "" && (line[0] == ' ' || line[0] == '\t'):
+			// Indented lines continue the current list item
+			listItems[len(listItems)-1] += "\n" + trimmed
+		default:
+			flushList()
+			paragraph = append(paragraph, line)
+		}
+	}
+	flushParagraph()
+	flushList()
+
+	if len(blocks) == 1 && strings.HasPrefix(blocks[0], "<p>") {
+		return strings.TrimSuffix(strings.TrimPrefix(blocks[0], "<p>"), "</p>")
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// markdownLines renders the lines of one block. A line ending in two spaces
+// or a backslash is followed by a hard line break.
+func markdownLines(lines []string) string {
+	var sb strings.Builder
+	for i, line := range lines {
+		hardBreak := strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\")
+		line = strings.TrimSpace(line)
+		if hardBreak {
+			line = strings.TrimSuffix(line, "\\")
+		}
+		sb.WriteString(markdownInline(line))
+		if i < len(lines)-1 {
+			if hardBreak {
+				sb.WriteString("<br>")
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// markdownInline renders emphasis, code spans, links and autolinks in s.
+// Everything else is escaped.
+func markdownInline(s string) string {
+	// pieces holds the output, with an empty piece for each run of * or _
+	// that matchEmphasis turns into tags or text once every run is known
+	var (
+		sb     strings.Builder
+		pieces []string
+		delims []delimiter
+	)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch c {
+		case '\\':
+			if i+1 < len(s) && strings.IndexByte(markdownEscapeCh, s[i+1]) >= 0 {
+				sb.WriteString(html.EscapeString(s[i+1 : i+2]))
+				i += 2
+				continue
+			}
+		case '`':
+			n := runLength(s, i, '`')
+			if end := strings.Index(s[i+n:], s[i:i+n]); end >= 0 {
+				code := s[i+n : i+n+end]
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+					code = code[1 : len(code)-1]
+				}
+				sb.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += n + end + n
+				continue
+			}
+			sb.WriteString(s[i : i+n])
+			i += n
+			continue
+		case '*', '_':
+			n := runLength(s, i, c)
+			d := newDelimiter(s, i, n)
+			pieces = append(pieces, sb.String(), "")
+			sb.Reset()
+			d.piece = len(pieces) - 1
+			delims = append(delims, d)
+			i += n
+			continue
+		case '[':
+			if out, next, ok := markdownLink(s, i); ok {
+				sb.WriteString(out)
+				i = next
+				continue
+			}
+		case '<':
+			if m := autolinkPattern.FindStringSubmatch(s[i:]); m != nil {
+				target := m[1]
+				if !strings.Contains(target, ":") {
+					target = "mailto:" + target
+				}
+				if href := safeURL(target); href != "" {
+					sb.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(m[1]) + `</a>`)
+				} else {
+					sb.WriteString(html.EscapeString(m[0]))
+				}
+				i += len(m[0])
+				continue
+			}
+		}
+		sb.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	pieces = append(pieces, sb.String())
+
+	matchEmphasis(delims)
+	for _, d := range delims {
+		pieces[d.piece] = strings.Join(d.closes, "") + strings.Repeat(string(d.char), d.count) + strings.Join(d.opens, "")
+	}
+	return strings.Join(pieces, "")
+}
+
+// delimiter is a run of * or _ that may open or close emphasis
+type delimiter struct {
+	piece             int // index of the run's output piece
+	char              byte
+	length            int // length of the run as written
+	count             int // delimiters not yet matched
+	canOpen, canClose bool
+	// prev and next link the delimiters that may still match
+	prev, next int
+	// opens are the tags the run opens, outermost first; closes are the
+	// tags it closes, innermost first
+	opens, closes []string
+}
+
+// newDelimiter classifies the run of n delimiters at s[i] by the
+// characters around it, following CommonMark: a run can open emphasis when
+// it is not followed by a space and can close it when it is not preceded by
+// one. An underscore inside a word, as in snake_case, does neither.
+func newDelimiter(s string, i, n int) delimiter {
+	before, after := ' ', ' '
+	if i > 0 {
+		before, _ = utf8.DecodeLastRuneInString(s[:i])
+	}
+	if i+n < len(s) {
+		after, _ = utf8.DecodeRuneInString(s[i+n:])
+	}
+	leftFlanking := !unicode.IsSpace(after) && (!isPunct(after) || unicode.IsSpace(before) || isPunct(before))
+	rightFlanking := !unicode.IsSpace(before) && (!isPunct(before) || unicode.IsSpace(after) || isPunct(after))
+
+	d := delimiter{char: s[i], length: n, count: n, canOpen: leftFlanking, canClose: rightFlanking}
+	if d.char == '_' {
+		d.canOpen = leftFlanking && (!rightFlanking || isPunct(before))
+		d.canClose = rightFlanking && (!leftFlanking || isPunct(after))
+	}
+	return d
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// matchEmphasis pairs openers with closers as CommonMark's delimiter stack
+// does, recording the <em> and <strong> tags on the runs. Each closer looks
+// back for the nearest opener of the same kind; openersBottom remembers how
+// far a failed search went, so every run is passed over a bounded number
+// of times and the work stays linear in the number of runs.
+func matchEmphasis(delims []delimiter) {
+	for i := range delims {
+		delims[i].prev, delims[i].next = i-1, i+1
+	}
+	if len(delims) > 0 {
+		delims[len(delims)-1].next = -1
+	}
+	remove := func(i int) {
+		d := delims[i]
+		if d.prev != -1 {
+			delims[d.prev].next = d.next
+		}
+		if d.next != -1 {
+			delims[d.next].prev = d.prev
+		}
+	}
+
+	type bottomKey struct {
+		char    byte
+		canOpen bool
+		mod3    int
+	}
+	openersBottom := make(map[bottomKey]int)
+
+	for c := 0; c != -1 && c < len(delims); {
+		closer := &delims[c]
+		if !closer.canClose {
+			c = closer.next
+			continue
+		}
+		key := bottomKey{closer.char, closer.canOpen, closer.length % 3}
+		bottom, ok := openersBottom[key]
+		if !ok {
+			bottom = -1
+		}
+
+		o := closer.prev
+		for ; o > bottom; o = delims[o].prev {
+			opener := &delims[o]
+			if opener.char != closer.char || !opener.canOpen {
+				continue
+			}
+			// The rule of three keeps *a**b* from pairing * with **
+			if (opener.canClose || closer.canOpen) && (opener.length+closer.length)%3 == 0 &&
+				(opener.length%3 != 0 || closer.length%3 != 0) {
+				continue
+			}
+			break
+		}
+		if o <= bottom {
+			openersBottom[key] = closer.prev
+			next := closer.next
+			if !closer.canOpen {
+				remove(c)
+			}
+			c = next
+			continue
+		}
+
+		opener := &delims[o]
+		tag, used := "em", 1
+		if opener.count >= 2 && closer.count >= 2 {
+			tag, used = "strong", 2
+		}
+		opener.opens = append([]string{"<" + tag + ">"}, opener.opens...)
+		closer.closes = append(closer.closes, "</"+tag+">")
+		opener.count -= used
+		closer.count -= used
+
+		// Runs between the pair can no longer match
+		opener.next, closer.prev = c, o
+		if opener.count == 0 {
+			remove(o)
+		}
+		if closer.count == 0 {
+			next := closer.next
+			remove(c)
+			c = next
+		}
+	}
+}
+
+// markdownLink renders the [text](url) link starting at s[i]. Links to
+// unsafe URLs are rendered as plain text.
+func markdownLink(s string, i int) (string, int, bool) {
+	depth := 0
+	closeText := -1
+	for j := i; j < len(s) && closeText < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeText = j
+			}
+		}
+	}
+	if closeText < 0 || closeText+1 >= len(s) || s[closeText+1] != '(' {
+		return "", 0, false
+	}
+	// The destination may hold balanced parentheses
+	closeURL, parens := -1, 0
+	for j := closeText + 2; j < len(s) && closeURL < 0; j++ {
+		switch s[j] {
+		case '(':
+			parens++
+		case ')':
+			if parens == 0 {
+				closeURL = j - closeText - 2
+			}
+			parens--
+		}
+	}
+	if closeURL < 0 {
+		return "", 0, false
+	}
+
+	text := markdownInline(s[i+1 : closeText])
+	target := strings.TrimSpace(s[closeText+2 : closeText+2+closeURL])
+	// Drop an optional "title"
+	if sp := strings.IndexAny(target, " \t"); sp >= 0 {
+		target = target[:sp]
+	}
+	target = strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+	next := closeText + 2 + closeURL + 1
+
+	if href := safeURL(target); href != "" {
+		return `<a href="` + html.EscapeString(href) + `">` + text + `</a>`, next, true
+	}
+	return text, next, true
+}
+
+func runLength(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
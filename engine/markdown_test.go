@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		// Emphasis
+		{"em", "*a*", "<em>a</em>"},
+		{"strong", "**a**", "<strong>a</strong>"},
+		{"underscores", "_a_ and __b__", "<em>a</em> and <strong>b</strong>"},
+		{"both", "***both***", "<em><strong>both</strong></em>"},
+		{"strong inside em", "***a** b*", "<em><strong>a</strong> b</em>"},
+		{"em inside strong", "***a* b**", "<strong><em>a</em> b</strong>"},
+		{"strong inside em text", "*a **b** c*", "<em>a <strong>b</strong> c</em>"},
+		{"em inside strong text", "**a *b* c**", "<strong>a <em>b</em> c</strong>"},
+		{"unclosed", "*a", "*a"},
+		{"space after opener", "a * b *", "a * b *"},
+		{"intraword underscore", "snake_case_name", "snake_case_name"},
+		{"intraword star", "a*b*c", "a<em>b</em>c"},
+		{"delimiter in code span", "*a `*` b*", "<em>a <code>*</code> b</em>"},
+		{"rule of three", "*a**b*", "<em>a**b</em>"},
+		{"extra opener", "**a*", "*<em>a</em>"},
+		{"extra closer", "*a**", "<em>a</em>*"},
+		{"mixed delimiters", "*a _b* c_", "<em>a _b</em> c_"},
+		{"punctuation", "**(a)**", "<strong>(a)</strong>"},
+		{"underscore after punctuation", "(_a_)", "(<em>a</em>)"},
+		{"emphasis in link text", "*[a*](/x)", `*<a href="/x">a*</a>`},
+		{"escaped delimiter", `\*a\*`, "*a*"},
+
+		// Code spans
+		{"code", "`a < b`", "<code>a &lt; b</code>"},
+		{"double backticks", "`` a`b ``", "<code>a`b</code>"},
+		{"unclosed code", "`a", "`a"},
+
+		// Links
+		{"link", "[Go](https://go.dev)", `<a href="https://go.dev">Go</a>`},
+		{"link with title", `[Go](https://go.dev "Go")`, `<a href="https://go.dev">Go</a>`},
+		{"link with parentheses", "[w](https://x.org/a_(b))", `<a href="https://x.org/a_(b)">w</a>`},
+		{"link text emphasis", "[*Go*](/go)", `<a href="/go"><em>Go</em></a>`},
+		{"javascript link", "[x](javascript:alert(1))", "x"},
+		{"javascript link uppercase", "[x](JavaScript:alert(1))", "x"},
+		{"data link", "[x](data:text/html,hi)", "x"},
+		{"mailto link", "[me](mailto:a@b.c)", `<a href="mailto:a@b.c">me</a>`},
+		{"autolink", "<https://go.dev>", `<a href="https://go.dev">https://go.dev</a>`},
+		{"email autolink", "<a@b.c>", `<a href="mailto:a@b.c">a@b.c</a>`},
+		{"javascript autolink", "<javascript:alert(1)>", "&lt;javascript:alert(1)&gt;"},
+		{"not a link", "[a] (b)", "[a] (b)"},
+
+		// Escaping
+		{"raw html", "<b>x</b> & <script>", "&lt;b&gt;x&lt;/b&gt; &amp; &lt;script&gt;"},
+		{"quotes", `say "hi"`, "say &#34;hi&#34;"},
+		{"href is escaped", `[x](/a"b)`, `<a href="/a&#34;b">x</a>`},
+
+		// Hard breaks
+		{"two spaces", "a  \nb", "a<br>\nb"},
+		{"backslash", "a\\\nb", "a<br>\nb"},
+		{"soft break", "a\nb", "a\nb"},
+
+		// Blocks
+		{"paragraphs", "a\n\nb", "<p>a</p>\n<p>b</p>"},
+		{"heading", "## Skills ##", "<h2>Skills</h2>"},
+		{"fenced code", "```\n<a>\n```", "<pre><code>&lt;a&gt;</code></pre>"},
+		{"list", "- a\n- b", "<ul><li>a</li><li>b</li></ul>"},
+		{"ordered list", "1. a\n2) b", "<ol><li>a</li><li>b</li></ol>"},
+		{"loose list", "* a\n\n* b", "<ul><li>a</li><li>b</li></ul>"},
+		{"list continuation", "- a\n  more\n- b", "<ul><li>a\nmore</li><li>b</li></ul>"},
+		{"list then paragraph", "- a\n\nb", "<ul><li>a</li></ul>\n<p>b</p>"},
+		{"list kinds", "- a\n1. b", "<ul><li>a</li></ul>\n<ol><li>b</li></ol>"},
+		{"list item emphasis", "- **a** b", "<ul><li><strong>a</strong> b</li></ul>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderMarkdown(tt.src); got != tt.want {
+				t.Errorf("renderMarkdown(%q) = %q, want %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+// Unmatched delimiters must not make rendering slow: each run is looked at
+// a bounded number of times
+func TestRenderMarkdownUnmatchedDelimiters(t *testing.T) {
+	inputs := []string{
+		strings.Repeat("*a ", 5000),
+		strings.Repeat("*a _b ", 5000),
+		strings.Repeat("**a _", 5000),
+		strings.Repeat("a* ", 5000) + strings.Repeat("*b ", 5000),
+		strings.Repeat("*", 20000),
+		strings.Repeat("_a*", 5000),
+	}
+	for _, src := range inputs {
+		start := time.Now()
+		out := renderMarkdown(src)
+		if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+			t.Errorf("renderMarkdown(%.20q...) took %v", src, elapsed)
+		}
+		if out == "" {
+			t.Errorf("renderMarkdown(%.20q...) returned nothing", src)
+		}
+	}
+}
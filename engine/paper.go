@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PaperSizes maps paper names accepted by SetPaper to width and height in
+// inches (portrait).
+var PaperSizes = map[string][2]float64{
+	"a3":      {11.69, 16.54},
+	"a4":      {8.27, 11.69},
+	"a5":      {5.83, 8.27},
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+}
+
+// units converts a length unit to inches
+var units = map[string]float64{
+	"in": 1,
+	"cm": 1 / 2.54,
+	"mm": 1 / 25.4,
+	"pt": 1.0 / 72,
+	"px": 1.0 / 96,
+}
+
+// SetPaper sets the paper size from a name such as "a4" or "letter", or from
+// explicit dimensions such as "210mmx297mm". An explicit paper size takes
+// precedence over any @page size in the template's CSS.
+func (o *PDFOptions) SetPaper(spec string) error {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if size, ok := PaperSizes[spec]; ok {
+		o.PaperWidth, o.PaperHeight = size[0], size[1]
+		o.PreferCSSPageSize = false
+		return nil
+	}
+
+	w, h, found := strings.Cut(spec, "x")
+	if !found {
+		return fmt.Errorf("unknown paper size: %q", spec)
+	}
+	width, err := parseLength(w)
+	if err != nil {
+		return fmt.Errorf("invalid paper width: %w", err)
+	}
+	height, err := parseLength(h)
+	if err != nil {
+		return fmt.Errorf("invalid paper height: %w", err)
+	}
+	o.PaperWidth, o.PaperHeight = width, height
+	o.PreferCSSPageSize = false
+	return nil
+}
+
+// SetMargins sets the margins from a CSS-style shorthand of one to four
+// lengths: "10mm", "10mm 15mm", "10mm 15mm 12mm" or "10mm 15mm 12mm 15mm".
+func (o *PDFOptions) SetMargins(spec string) error {
+	fields := strings.Fields(spec)
+	values := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := parseLength(f)
+		if err != nil {
+			return fmt.Errorf("invalid margin: %w", err)
+		}
+		values[i] = v
+	}
+
+	switch len(values) {
+	case 1:
+		o.MarginTop, o.MarginRight, o.MarginBottom, o.MarginLeft = values[0], values[0], values[0], values[0]
+	case 2:
+		o.MarginTop, o.MarginRight, o.MarginBottom, o.MarginLeft = values[0], values[1], values[0], values[1]
+	case 3:
+		o.MarginTop, o.MarginRight, o.MarginBottom, o.MarginLeft = values[0], values[1], values[2], values[1]
+	case 4:
+		o.MarginTop, o.MarginRight, o.MarginBottom, o.MarginLeft = values[0], values[1], values[2], values[3]
+	default:
+		return fmt.Errorf("invalid margin: %q (use one to four lengths)", spec)
+	}
+	return nil
+}
+
+// SetHeaderTemplate sets the page header. tmpl is either a path to an HTML
+// file or the HTML itself.
+func (o *PDFOptions) SetHeaderTemplate(tmpl string) error {
+	html, err := readTemplateArg(tmpl)
+	if err != nil {
+		return err
+	}
+	o.HeaderTemplate = html
+	o.DisplayHeaderFooter = o.HeaderTemplate != "" || o.FooterTemplate != ""
+	return nil
+}
+
+// SetFooterTemplate sets the page footer. tmpl is either a path to an HTML
+// file or the HTML itself.
+func (o *PDFOptions) SetFooterTemplate(tmpl string) error {
+	html, err := readTemplateArg(tmpl)
+	if err != nil {
+		return err
+	}
+	o.FooterTemplate = html
+	o.DisplayHeaderFooter = o.HeaderTemplate != "" || o.FooterTemplate != ""
+	return nil
+}
+
+// readTemplateArg returns the contents of tmpl if it names a file, otherwise
+// tmpl itself
+func readTemplateArg(tmpl string) (string, error) {
+	if strings.ContainsAny(tmpl, "<>") {
+		return tmpl, nil
+	}
+	if stat, err := os.Stat(tmpl); err == nil && !stat.IsDir() {
+		content, err := os.ReadFile(tmpl)
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
+	}
+	return tmpl, nil
+}
+
+// parseLength converts a length such as "10mm" or "0.5in" to inches. A bare
+// number is taken as inches.
+func parseLength(s string) (float64, error) {
+	orig := s
+	s = strings.ToLower(strings.TrimSpace(s))
+	factor := 1.0
+	for unit, f := range units {
+		if strings.HasSuffix(s, unit) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit))
+			factor = f
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid length: %q", orig)
+	}
+	return v * factor, nil
+}
@@ -0,0 +1,344 @@
+package engine
+
+import (
+	"cvforge/types"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Formatter transforms a value on its way into the document. Formatters
+// are chained after a path with pipes:
+//
+//	value-of="startDate | date:'Jan 2006'"
+//	value-of="summary | truncate:120 | default:'—'"
+//
+// value is nil when the path resolves to nothing. args are the arguments
+// written after the formatter name, separated by colons. Before rendering,
+// every formatter in the template is called once with empty text to check
+// its arguments, so it should only fail there when they are wrong.
+type Formatter func(value types.CVBase, args ...string) (types.CVBase, error)
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = map[string]Formatter{
+		"upper":    StringFormatter(noArgs(strings.ToUpper)),
+		"lower":    StringFormatter(noArgs(strings.ToLower)),
+		"trim":     StringFormatter(noArgs(strings.TrimSpace)),
+		"date":     StringFormatter(formatDate),
+		"truncate": StringFormatter(truncate),
+		"default":  defaultValue,
+		"join":     join,
+	}
+)
+
+// RegisterFormatter makes f available to templates as name, replacing any
+// formatter already registered under that name, built-in ones included.
+func RegisterFormatter(name string, f Formatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	formatters[name] = f
+}
+
+func lookupFormatter(name string) (Formatter, bool) {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	f, ok := formatters[name]
+	return f, ok
+}
+
+// StringFormatter adapts f into a Formatter that applies it to text, or to
+// every item of a list. The value keeps its tags, URL and format; other
+// values pass through unchanged.
+func StringFormatter(f func(s string, args ...string) (string, error)) Formatter {
+	var format Formatter
+	format = func(value types.CVBase, args ...string) (types.CVBase, error) {
+		switch v := value.(type) {
+		case types.CVForgeString:
+			s, err := f(v.Value, args...)
+			if err != nil {
+				return nil, err
+			}
+			v.Value = s
+			return v, nil
+		case *types.CVForgeString:
+			return format(*v, args...)
+		case types.CVForgeSlice:
+			items := make([]types.CVBase, len(v.Value))
+			for i, item := range v.Value {
+				formatted, err := format(item, args...)
+				if err != nil {
+					return nil, err
+				}
+				items[i] = formatted
+			}
+			v.Value = items
+			return v, nil
+		}
+		return value, nil
+	}
+	return format
+}
+
+func noArgs(f func(string) string) func(string, ...string) (string, error) {
+	return func(s string, args ...string) (string, error) {
+		if len(args) > 0 {
+			return "", fmt.Errorf("takes no arguments")
+		}
+		return f(s), nil
+	}
+}
+
+// formatDate rewrites a date such as 2022-06 with a Go layout such as
+// 'Jan 2006'. Text that is not a date, like Present, is left as it is.
+func formatDate(s string, args ...string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("needs a layout such as 'Jan 2006'")
+	}
+	text := strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t.Format(args[0]), nil
+		}
+	}
+	return s, nil
+}
+
+// truncate shortens text to at most n characters, breaking at a space when
+// one is near and ending with the suffix, "…" unless given
+func truncate(s string, args ...string) (string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", fmt.Errorf("needs a length and an optional suffix")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("length %q is not a whole number", args[0])
+	}
+	suffix := "…"
+	if len(args) == 2 {
+		suffix = args[1]
+	}
+
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s, nil
+	}
+	cut := runes[:n]
+	if space := strings.LastIndexFunc(string(cut), unicode.IsSpace); space > len(string(cut))/2 {
+		cut = []rune(string(cut)[:space])
+	}
+	return strings.TrimRightFunc(string(cut), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + suffix, nil
+}
+
+// defaultValue replaces a missing or empty value with its argument
+func defaultValue(value types.CVBase, args ...string) (types.CVBase, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("needs the text to show")
+	}
+	if value != nil && strings.TrimSpace(getStringValue(value)) != "" {
+		return value, nil
+	}
+	if m, ok := value.(types.CVForgeMap); ok && len(m.Value) > 0 {
+		return value, nil
+	}
+	return types.CVForgeString{CVTagInfo: types.DefaultCVTagInfo(), Value: args[0]}, nil
+}
+
+// join turns a list, or the "key: value" pairs of a map, into text
+// separated by the argument, ", " unless given
+func join(value types.CVBase, args ...string) (types.CVBase, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("takes one separator")
+	}
+	sep := defaultJoin
+	if len(args) == 1 {
+		sep = args[0]
+	}
+	switch v := value.(type) {
+	case types.CVForgeSlice:
+		parts := make([]string, 0, len(v.Value))
+		for _, item := range v.Value {
+			if s := getStringValue(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return types.CVForgeString{CVTagInfo: v.CVTagInfo, Value: strings.Join(parts, sep)}, nil
+	case types.CVForgeMap:
+		return types.CVForgeString{CVTagInfo: v.CVTagInfo, Value: mapString(v, sep)}, nil
+	}
+	return value, nil
+}
+
+// pipeStep is one formatter of a pipeline with its arguments
+type pipeStep struct {
+	name   string
+	args   []string
+	format Formatter
+}
+
+type pipeline []pipeStep
+
+// splitPipeline splits "path | f:arg" into the path and the formatters,
+// which keep their leading "|" and are "" when there are none
+func splitPipeline(expr string) (path, pipes string) {
+	if idx := strings.Index(expr, "|"); idx != -1 {
+		return strings.TrimSpace(expr[:idx]), expr[idx:]
+	}
+	return strings.TrimSpace(expr), ""
+}
+
+// parsePipeline parses the formatters splitPipeline returns. Arguments
+// may be quoted with ' or " to hold spaces, colons or pipes.
+func parsePipeline(pipes string) (pipeline, error) {
+	var steps pipeline
+	for _, part := range splitOutsideQuotes(strings.TrimPrefix(strings.TrimSpace(pipes), "|"), '|') {
+		fields := splitOutsideQuotes(part, ':')
+		name := strings.TrimSpace(fields[0])
+		if name == "" {
+			return nil, fmt.Errorf("missing formatter name")
+		}
+		format, ok := lookupFormatter(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown formatter %q", name)
+		}
+		step := pipeStep{name: name, format: format}
+		for _, field := range fields[1:] {
+			arg, err := unquoteArg(field)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			step.args = append(step.args, arg)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// apply runs the pipeline on value. A formatter that fails leaves the
+// value as it was; the first error is returned along with the result.
+func (p pipeline) apply(value types.CVBase) (types.CVBase, error) {
+	var firstErr error
+	for _, step := range p {
+		formatted, err := step.format(value, step.args...)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", step.name, err)
+			}
+			continue
+		}
+		value = formatted
+	}
+	return value, firstErr
+}
+
+// check runs the pipeline on empty text, which makes formatters report
+// bad arguments before they see any data
+func (p pipeline) check() error {
+	_, err := p.apply(types.CVForgeString{})
+	return err
+}
+
+// resolvePipeline resolves the path of expr with resolve and runs the
+// value through its formatters. Pipelines and their arguments are checked
+// by checkPipelines before rendering, so one that does not parse returns
+// the plain value, and a formatter that fails on the data leaves it as it
+// was.
+func resolvePipeline(expr string, resolve func(path string) types.CVBase) types.CVBase {
+	path, pipes := splitPipeline(expr)
+	value := resolve(path)
+	if pipes == "" {
+		return value
+	}
+	steps, err := parsePipeline(pipes)
+	if err != nil {
+		return value
+	}
+	value, _ = steps.apply(value)
+	return value
+}
+
+// checkPipelines parses and checks the formatters of every content and
+// attr-* directive below root, naming the element of the first one that
+// does not parse or has invalid arguments
+func checkPipelines(root *goquery.Selection) error {
+	var err error
+	root.Find("*").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		for _, attr := range s.Nodes[0].Attr {
+			var exprs []string
+			switch {
+			case slices.Contains(contentAttrs, attr.Key):
+				exprs = []string{attr.Val}
+			case strings.HasPrefix(attr.Key, attrPrefix):
+				exprs, _ = bindingPaths(attr.Val)
+			}
+			for _, expr := range exprs {
+				if _, pipes := splitPipeline(expr); pipes != "" {
+					steps, perr := parsePipeline(pipes)
+					if perr == nil {
+						perr = steps.check()
+					}
+					if perr != nil {
+						err = fmt.Errorf("%s: %s %q: %w", describeElement(s), attr.Key, expr, perr)
+						return false
+					}
+				}
+			}
+		}
+		return true
+	})
+	return err
+}
+
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquoteArg(arg string) (string, error) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" || (arg[0] != '\'' && arg[0] != '"') {
+		return arg, nil
+	}
+	quote := arg[0]
+	var sb strings.Builder
+	for i := 1; i < len(arg); i++ {
+		switch c := arg[i]; {
+		case c == '\\' && i+1 < len(arg):
+			i++
+			sb.WriteByte(arg[i])
+		case c == quote:
+			if rest := strings.TrimSpace(arg[i+1:]); rest != "" {
+				return "", fmt.Errorf("unexpected %q after quoted argument", rest)
+			}
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated quote in %s", arg)
+}
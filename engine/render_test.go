@@ -0,0 +1,281 @@
+package engine
+
+import (
+	"context"
+	"cvforge/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// renderBody renders template against the YAML data as HTML and returns
+// the inner HTML of its body
+func renderBody(t *testing.T, template, data string) (string, error) {
+	t.Helper()
+	return renderBodyWith(t, template, data, RenderOptions{})
+}
+
+func renderBodyWith(t *testing.T, template, data string, opts RenderOptions) (string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "template.html")
+	if err := os.WriteFile(path, []byte(template), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := types.DecodeYAML([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cv, _ := types.UnmarshalCVBase(raw, types.DefaultCVTagInfo())
+
+	out, err := Render(context.Background(), path, cv, OutputHTML, opts)
+	if err != nil {
+		return "", err
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := doc.Find("body").Html()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(body), nil
+}
+
+const repeatIfData = `
+jobs:
+  - company: A
+    end: Present
+  - company: B
+    end: "2021"
+  - company: C
+    end: Present
+  - company: D
+    end: Present
+`
+
+func TestRepeatForIf(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name:     "filters items",
+			template: `<p repeat-for="jobs" if="end == 'Present'"><b value-of="company"></b></p>`,
+			want:     `<p><b>A</b></p><p><b>C</b></p><p><b>D</b></p>`,
+		},
+		{
+			name:     "loop variables count kept items",
+			template: `<p repeat-for="jobs" if="end != 'Present'"><b value-of="$number"></b>/<b value-of="$count"></b></p>`,
+			want:     `<p><b>1</b>/<b>1</b></p>`,
+		},
+		{
+			name:     "limit counts kept items",
+			template: `<p repeat-for="jobs" if="end == 'Present'" limit="2" offset="1"><b value-of="company"></b></p>`,
+			want:     `<p><b>C</b></p><p><b>D</b></p>`,
+		},
+		{
+			name:     "after sort",
+			template: `<p repeat-for="jobs" sort-by="company" order="desc" if="$index &lt; 2"><b value-of="company"></b></p>`,
+			want:     `<p><b>D</b></p><p><b>C</b></p>`,
+		},
+		{
+			name:     "on groups",
+			template: `<p repeat-for="jobs" group-by="end" if="len($items) > 1"><b value-of="$key"></b></p>`,
+			want:     `<p><b>Present</b></p>`,
+		},
+		{
+			name:     "no item matches",
+			template: `<p repeat-for="jobs" if="company == 'Z'">x</p>`,
+			want:     ``,
+		},
+		{
+			name:     "if on a clone's children",
+			template: `<p repeat-for="jobs"><i if="end == 'Present'" value-of="company"></i><i else>past</i></p>`,
+			want:     `<p><i>A</i></p><p><i>past</i></p><p><i>C</i></p><p><i>D</i></p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderBody(t, tt.template, repeatIfData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatterErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string // error, or the body when rendering succeeds
+	}{
+		{`<p value-of="name | truncate:abc"></p>`, `<p>: value-of "name | truncate:abc": truncate: length "abc" is not a whole number`},
+		{`<p value-of="start | date"></p>`, `<p>: value-of "start | date": date: needs a layout such as 'Jan 2006'`},
+		{`<p id="n" value-of="name | upper:x"></p>`, `<p id="n">: value-of "name | upper:x": upper: takes no arguments`},
+		{`<p value-of="name | default"></p>`, `<p>: value-of "name | default": default: needs the text to show`},
+		{`<a attr-title="{name | join:a:b}"></a>`, `<a>: attr-title "name | join:a:b": join: takes one separator`},
+		{`<p value-of="name | shout"></p>`, `<p>: value-of "name | shout": unknown formatter "shout"`},
+		{`<p value-of="name | truncate:2:'.'"></p>`, `<p>Ad.</p>`},
+		{`<p value-of="start | date:'Jan 2006'"></p>`, `<p>Jun 2022</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := renderBody(t, tt.template, "name: Ada Lovelace\nstart: 2022-06\n")
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepeatForCommaList(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`<i repeat-for="list" value-of="list"></i>`, `<i>x</i><i>y</i><i>z</i>`},
+		{`<i repeat-for="list"><b value-of="list"></b></i>`, `<i><b>x</b></i><i><b>y</b></i><i><b>z</b></i>`},
+		{`<i repeat-for="list" value-of="$value | upper"></i>`, `<i>X</i><i>Y</i><i>Z</i>`},
+		{`<i repeat-for="list" attr-title="{list}"></i>`, `<i title="x"></i><i title="y"></i><i title="z"></i>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := renderBody(t, tt.template, `list: "x, y, z"`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLBindings(t *testing.T) {
+	const data = `
+photo: photos/me.jpg
+spaced: my photo 100%.jpg
+inline: data:image/png;base64,iVBORw0KGgo=
+svg: data:image/svg+xml;base64,PHN2Zz4=
+page: data:text/html,hi
+script: javascript:alert(1)
+tabbed: "java\tscript:alert(1)"
+site:
+  value: Site
+  url: vbscript:msgbox
+`
+	tests := []struct {
+		template string
+		want     string
+		warning  string
+	}{
+		{`<img attr-src="photo"/>`, `<img src="photos/me.jpg"/>`, ""},
+		{`<img attr-src="spaced"/>`, `<img src="my photo 100%.jpg"/>`, ""},
+		{`<img attr-src="inline"/>`, `<img src="data:image/png;base64,iVBORw0KGgo="/>`, ""},
+		{`<img attr-src="svg"/>`, `<img/>`, `<img>: attr-src "data:image/svg+xml;base64,PHN2Zz4=" left out: unsafe URL`},
+		{`<iframe attr-src="page"></iframe>`, `<iframe></iframe>`, `<iframe>: attr-src "data:text/html,hi" left out: unsafe URL`},
+		{`<a attr-href="inline">x</a>`, `<a>x</a>`, `<a>: attr-href "data:image/png;base64,iVBORw0KGgo=" left out: unsafe URL`},
+		{`<a attr-href="script">x</a>`, `<a>x</a>`, `<a>: attr-href "javascript:alert(1)" left out: unsafe URL`},
+		{`<a attr-href="tabbed">x</a>`, `<a>x</a>`, `<a>: attr-href "java\tscript:alert(1)" left out: unsafe URL`},
+		{`<a attr-href="photo">x</a>`, `<a href="photos/me.jpg">x</a>`, ""},
+		{`<span value-of="site"></span>`, `<span>Site</span>`, `<span>: url "vbscript:msgbox" left out: unsafe URL`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			var warnings []string
+			opts := RenderOptions{Warn: func(message string) { warnings = append(warnings, message) }}
+			got, err := renderBodyWith(t, tt.template, data, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+			if strings.Join(warnings, "\n") != tt.warning {
+				t.Errorf("warnings = %q, want %q", warnings, tt.warning)
+			}
+		})
+	}
+}
+
+func TestRepeatForItemTags(t *testing.T) {
+	data := `
+langs:
+  - {value: Go, tags: [go]}
+  - {value: Rust, tags: [rust]}
+  - {value: [Python, Django], tags: [go, web]}
+  - {name: Zig, tags: [go]}
+`
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name:     "if on tags",
+			template: `<i repeat-for="langs" if="'go' in tags" value-of="$index"></i>`,
+			want:     `<i>0</i><i>1</i><i>2</i>`,
+		},
+		{
+			name:     "tags of $value",
+			template: `<i repeat-for="langs" if="'rust' in $value.tags" value-of="$value"></i>`,
+			want:     `<i>Rust</i>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderBody(t, tt.template, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathExpressions(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"value-of", `<p class="n" value-of="len(jobs)"></p>`, `<p class="n">: value-of: expression "len(jobs)" can only be used with if`},
+		{"value-of with formatters", `<p value-of="len(jobs) | upper"></p>`, `<p>: value-of: expression "len(jobs)" can only be used with if`},
+		{"attr binding", `<p attr-title="len(jobs)"></p>`, `<p>: attr-title: expression "len(jobs)" can only be used with if`},
+		{"attr template", `<p attr-class="job {$first && 'first'}"></p>`, `<p>: attr-class: expression "$first && 'first'" can only be used with if`},
+		{"unparsed expression", `<p value-of="len(jobs"></p>`, `<p>: value-of: expression "len(jobs" at column 9: missing ) after arguments to len`},
+		{"if-exists", `<p id="x" if-exists="len(jobs) > 1">x</p>`, `<p id="x">: if-exists: expression "len(jobs) > 1" can only be used with if`},
+		{"if-missing", `<p if-missing="!jobs">x</p>`, `<p>: if-missing: expression "!jobs" can only be used with if`},
+		{"repeat-for", `<p repeat-for="jobs == 1">x</p>`, `<p>: repeat-for: expression "jobs == 1" can only be used with if`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := renderBody(t, tt.template, repeatIfData)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// Plain paths, including keys with spaces and numbers, still render
+	got, err := renderBody(t, `<p value-of="jobs.0.company"></p><p value-of="job title"></p><p value-of="2023 | default:'-'"></p>`, "job title: Lead\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<p></p><p>Lead</p><p>-</p>`; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ErrRendererClosed is returned by Generate after Close has been called.
+var ErrRendererClosed = errors.New("pdf renderer is closed")
+
+// PDFRenderer keeps one headless Chrome running and prints documents in a
+// pool of tabs, so batch generation pays the browser start-up cost once.
+// It is safe for concurrent use; Close must be called to stop the browser.
+type PDFRenderer struct {
+	allocCancel   context.CancelFunc
+	browserCtx    context.Context
+	browserCancel context.CancelFunc
+
+	idle  chan *pdfTab  // tabs ready for reuse
+	slots chan struct{} // one token per tab that may still be opened
+
+	mu      sync.Mutex
+	closed  bool
+	pending sync.WaitGroup
+}
+
+type pdfTab struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewPDFRenderer starts a headless browser that prints up to tabs documents
+// at the same time. Tabs are opened on demand and reused afterwards.
+func NewPDFRenderer(tabs int) (*PDFRenderer, error) {
+	if tabs < 1 {
+		tabs = 1
+	}
+
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.NoSandbox,
+		chromedp.Headless,
+		chromedp.DisableGPU,
+		chromedp.NoFirstRun,
+		chromedp.NoDefaultBrowserCheck,
+	)
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	browserCtx, browserCancel := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf))
+
+	// Running an empty action launches the browser
+	if err := chromedp.Run(browserCtx); err != nil {
+		browserCancel()
+		allocCancel()
+		return nil, fmt.Errorf("failed to start browser: %w", err)
+	}
+
+	r := &PDFRenderer{
+		allocCancel:   allocCancel,
+		browserCtx:    browserCtx,
+		browserCancel: browserCancel,
+		idle:          make(chan *pdfTab, tabs),
+		slots:         make(chan struct{}, tabs),
+	}
+	for i := 0; i < tabs; i++ {
+		r.slots <- struct{}{}
+	}
+	return r, nil
+}
+
+// Generate prints htmlContent to PDF in one of the renderer's tabs. Relative
+// URLs in the document are resolved against assetRoots in order.
+func (r *PDFRenderer) Generate(ctx context.Context, htmlContent string, opts PDFOptions, assetRoots ...string) ([]byte, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrRendererClosed
+	}
+	r.pending.Add(1)
+	r.mu.Unlock()
+	defer r.pending.Done()
+
+	tab, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := httptest.NewServer(pageHandler{html: htmlContent, roots: assetRoots})
+	defer ts.Close()
+
+	// Cancelling runCtx aborts the run without closing the tab
+	runCtx, stop := context.WithCancel(tab.ctx)
+	defer stop()
+	defer context.AfterFunc(ctx, stop)()
+
+	var pdfBuffer []byte
+	err = chromedp.Run(runCtx,
+		chromedp.Navigate(ts.URL),
+		chromedp.WaitReady("body"),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			if opts.FitPages > 0 {
+				pdfBuffer, err = printToFit(ctx, opts)
+				return err
+			}
+			pdfBuffer, _, err = printParams(opts).Do(ctx)
+			return err
+		}),
+	)
+	r.release(tab, err != nil)
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("chromedp ile PDF oluşturma başarısız: %w", err)
+	}
+	return pdfBuffer, nil
+}
+
+// acquire returns an idle tab, opening a new one while the pool has room.
+func (r *PDFRenderer) acquire(ctx context.Context) (*pdfTab, error) {
+	select {
+	case tab := <-r.idle:
+		return tab, nil
+	default:
+	}
+
+	select {
+	case tab := <-r.idle:
+		return tab, nil
+	case <-r.slots:
+		tabCtx, cancel := chromedp.NewContext(r.browserCtx)
+		if err := chromedp.Run(tabCtx); err != nil {
+			cancel()
+			r.slots <- struct{}{}
+			return nil, fmt.Errorf("failed to open browser tab: %w", err)
+		}
+		return &pdfTab{ctx: tabCtx, cancel: cancel}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// release hands a tab back to the pool. Tabs that failed are closed and
+// their slot freed, so the next caller gets a fresh one.
+func (r *PDFRenderer) release(tab *pdfTab, broken bool) {
+	if broken {
+		tab.cancel()
+		r.slots <- struct{}{}
+		return
+	}
+	r.idle <- tab
+}
+
+// Close waits for in-flight documents, then shuts down the browser.
+func (r *PDFRenderer) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.mu.Unlock()
+
+	r.pending.Wait()
+	close(r.idle)
+	for tab := range r.idle {
+		tab.cancel()
+	}
+
+	err := chromedp.Cancel(r.browserCtx)
+	r.browserCancel()
+	r.allocCancel()
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("failed to close browser: %w", err)
+	}
+	return nil
+}
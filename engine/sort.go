@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"cvforge/types"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// repeat-for modifiers: sort-by="startDate" order="desc" limit="3" offset="1"
+const (
+	sortByAttr = "sort-by"
+	orderAttr  = "order"
+	limitAttr  = "limit"
+	offsetAttr = "offset"
+)
+
+// repeatModifiers lists the attributes that shape a repeat-for collection
+var repeatModifiers = []string{sortByAttr, orderAttr, groupByAttr, limitAttr, offsetAttr}
+
+// Values of the order modifier
+const (
+	orderAsc  = "asc"
+	orderDesc = "desc"
+)
+
+// ongoingDates mark a period that has not ended; they sort after every date
+var ongoingDates = []string{"present", "current", "now", "today", "ongoing", "günümüz", "halen", "devam ediyor"}
+
+// dateLayouts are the date formats sort-by recognises
+var dateLayouts = []string{
+	"2006-01-02", "2006-01", "2006/01", "01/2006", "01.2006", "2006",
+	"Jan 2006", "January 2006", "Jan. 2006", "02 Jan 2006", "2 January 2006",
+}
+
+// repeatEntry is one item of a repeat-for collection, with its map key
+type repeatEntry struct {
+	item types.CVBase
+	key  string
+}
+
+// applyRepeatModifiers sorts, groups, filters and slices entries as the
+// sort-by, order, group-by, if, offset and limit attributes of node ask, in
+// that order, so offset and limit count groups when group-by is set and
+// only items the if expression keeps. Items without a sort value go last in
+// either order. Invalid modifier values are ignored here; lint reports
+// them. grouped reports whether entries are now groups.
+func applyRepeatModifiers(node *goquery.Selection, context types.CVBase, repeatPath string, entries []repeatEntry, hasKeys bool) (_ []repeatEntry, grouped bool) {
+	order, _ := node.Attr(orderAttr)
+	desc, _ := parseOrder(order)
+
+	if sortBy, ok := node.Attr(sortByAttr); ok {
+		sortBy = strings.TrimPrefix(strings.TrimSpace(sortBy), repeatPath+".")
+
+		values := make([]sortValue, len(entries))
+		for i, e := range entries {
+			scope := loopScope(e.item, loopPosition{key: e.key, hasKey: hasKeys, count: len(entries)})
+			values[i] = parseSortValue(getCVBaseFromPath(scope, sortBy))
+		}
+		indices := make([]int, len(entries))
+		for i := range indices {
+			indices[i] = i
+		}
+		slices.SortStableFunc(indices, func(a, b int) int {
+			va, vb := values[a], values[b]
+			if va.missing || vb.missing {
+				// Missing values go last whatever the order
+				return boolCompare(va.missing, vb.missing)
+			}
+			if desc {
+				return compareSortValues(vb, va)
+			}
+			return compareSortValues(va, vb)
+		})
+		sorted := make([]repeatEntry, len(entries))
+		for i, idx := range indices {
+			sorted[i] = entries[idx]
+		}
+		entries = sorted
+	} else if desc {
+		// Without sort-by, order="desc" reverses the source order
+		entries = slices.Clone(entries)
+		slices.Reverse(entries)
+	}
+
+	if groupBy, ok := node.Attr(groupByAttr); ok {
+		groupBy = strings.TrimPrefix(strings.TrimSpace(groupBy), repeatPath+".")
+		entries, grouped = groupEntries(entries, groupBy, hasKeys), true
+	}
+
+	if expr, ok := node.Attr(ifAttr); ok {
+		// if is evaluated for each item, with the loop variables it would
+		// have without the filter
+		kept := make([]repeatEntry, 0, len(entries))
+		for i, e := range entries {
+			pos := loopPosition{index: i, count: len(entries), parent: context, group: grouped}
+			pos.key, pos.hasKey = e.key, hasKeys || grouped
+			if keep, _ := evalCondition(expr, loopScope(e.item, pos)); keep {
+				kept = append(kept, e)
+			}
+		}
+		entries = kept
+	}
+
+	if offset, ok := node.Attr(offsetAttr); ok {
+		if n, err := parseCount(offsetAttr, offset); err == nil {
+			entries = entries[min(n, len(entries)):]
+		}
+	}
+	if limit, ok := node.Attr(limitAttr); ok {
+		if n, err := parseCount(limitAttr, limit); err == nil {
+			entries = entries[:min(n, len(entries))]
+		}
+	}
+	return entries, grouped
+}
+
+func parseOrder(order string) (desc bool, err error) {
+	switch strings.ToLower(strings.TrimSpace(order)) {
+	case "", orderAsc:
+		return false, nil
+	case orderDesc:
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid order %q (use %s or %s)", order, orderAsc, orderDesc)
+}
+
+func parseCount(attr, value string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q (must be a whole number)", attr, value)
+	}
+	return n, nil
+}
+
+// sortValue is a sort key: a date, a number or text, in that preference
+type sortValue struct {
+	missing bool
+	date    time.Time
+	isDate  bool
+	number  float64
+	isNum   bool
+	text    string
+}
+
+func parseSortValue(cv types.CVBase) sortValue {
+	if cv == nil {
+		return sortValue{missing: true}
+	}
+	return parseSortText(getStringValue(cv))
+}
+
+// parseSortText recognises text as a date, a number or plain text
+func parseSortText(text string) sortValue {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return sortValue{missing: true}
+	}
+	v := sortValue{text: strings.ToLower(text)}
+
+	if slices.Contains(ongoingDates, v.text) {
+		v.date, v.isDate = time.Date(math.MaxInt32, 1, 1, 0, 0, 0, 0, time.UTC), true
+		return v
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			v.date, v.isDate = t, true
+			return v
+		}
+	}
+	if n, err := strconv.ParseFloat(text, 64); err == nil {
+		v.number, v.isNum = n, true
+	}
+	return v
+}
+
+func compareSortValues(a, b sortValue) int {
+	switch {
+	case a.isDate && b.isDate:
+		return a.date.Compare(b.date)
+	case a.isNum && b.isNum:
+		switch {
+		case a.number < b.number:
+			return -1
+		case a.number > b.number:
+			return 1
+		}
+		return 0
+	}
+	return naturalCompare(a.text, b.text)
+}
+
+// naturalCompare orders strings with runs of digits compared by value, so
+// "item 2" sorts before "item 10"
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		ra, rb := []rune(a)[0], []rune(b)[0]
+		if unicode.IsDigit(ra) && unicode.IsDigit(rb) {
+			na, restA := leadingNumber(a)
+			nb, restB := leadingNumber(b)
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+			a, b = restA, restB
+			continue
+		}
+		if ra != rb {
+			if ra < rb {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[len(string(ra)):], b[len(string(rb)):]
+	}
+	return len(a) - len(b)
+}
+
+func leadingNumber(s string) (int, string) {
+	end := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) })
+	if end == -1 {
+		end = len(s)
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n, s[end:]
+}
+
+func boolCompare(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,189 @@
+// iterate.go
+package main
+
+import (
+	"context"
+	"cvforge/engine"
+	"cvforge/types"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// --iterate modes: one variant per tag found in the data, one per profile
+// declared under _profiles, one per --group, or one per pair of tags
+const (
+	iterateTags     = "tags"
+	iterateProfiles = "profiles"
+	iterateGroups   = "groups"
+	iteratePairs    = "pairs"
+)
+
+// iterateModes lists every valid --iterate value
+var iterateModes = []string{iterateTags, iterateProfiles, iterateGroups, iteratePairs}
+
+// variant is one file rendered by --iterate
+type variant struct {
+	Name string
+	Expr types.TagExpr
+}
+
+// iterationVariants lists the variants --iterate=mode renders. Group and
+// pair variants keep anything tagged with any of their tags.
+func iterationVariants(mode string, data types.CVBase, profiles types.Profiles, groups []string) ([]variant, error) {
+	var variants []variant
+	switch mode {
+	case iterateProfiles:
+		if len(profiles) == 0 {
+			return nil, fmt.Errorf("--iterate=%s: the data declares no %s", iterateProfiles, types.ProfilesKey)
+		}
+		for _, p := range profiles {
+			variants = append(variants, variant{Name: p.Name, Expr: p.Expr})
+		}
+	case iterateGroups:
+		if len(groups) == 0 {
+			return nil, fmt.Errorf("--iterate=%s needs at least one --group", iterateGroups)
+		}
+		for _, group := range groups {
+			variants = append(variants, groupVariant(strings.Split(group, ",")...))
+		}
+	case iteratePairs:
+		tags := data.GetEveryTag()
+		for i := range tags {
+			for j := i + 1; j < len(tags); j++ {
+				variants = append(variants, groupVariant(tags[i], tags[j]))
+			}
+		}
+	default:
+		for _, tag := range data.GetEveryTag() {
+			variants = append(variants, variant{Name: tag, Expr: types.AnyTag(tag)})
+		}
+	}
+	return variants, nil
+}
+
+// groupVariant combines tags into one variant named "a+b"
+func groupVariant(tags ...string) variant {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			names = append(names, tag)
+		}
+	}
+	return variant{Name: strings.Join(names, "+"), Expr: types.AnyTag(names...)}
+}
+
+// iterationResult records how rendering one variant went
+type iterationResult struct {
+	Name     string
+	Path     string
+	Duration time.Duration
+	Skipped  bool
+	Dropped  int // list items removed to fit --max-pages
+	Err      error
+}
+
+// processIteration renders every variant to a file below outputPath named
+// by pattern, running up to jobs renders at once. Results are returned in
+// variant order; a file is only written when its render succeeded.
+func processIteration(ctx context.Context, outputPath, pattern string, data types.CVBase, variants []variant, templatePath string, outputFormat engine.OutputFormat, renderOpts engine.RenderOptions, jobs int) ([]iterationResult, error) {
+	paths, err := variantPaths(outputPath, pattern, variants, outputFormat, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(outputPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	results := make([]iterationResult, len(variants))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+
+	for i, v := range variants {
+		results[i] = iterationResult{
+			Name: v.Name,
+			Path: paths[i],
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(res *iterationResult, expr types.TagExpr) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			renderVariant(ctx, res, expr, data, templatePath, outputFormat, renderOpts)
+		}(&results[i], v.Expr)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// renderVariant filters data by expr, renders it and writes res.Path
+func renderVariant(ctx context.Context, res *iterationResult, expr types.TagExpr, data types.CVBase, templatePath string, outputFormat engine.OutputFormat, renderOpts engine.RenderOptions) {
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+
+	if err := ctx.Err(); err != nil {
+		res.Err = err
+		return
+	}
+
+	c, ok := data.Copy().Filter(expr)
+	if !ok {
+		res.Skipped = true
+		return
+	}
+	renderOpts.Filter = expr
+	renderOpts.Dropped = func(items []types.ListItem) { res.Dropped = len(items) }
+
+	result, err := engine.Render(ctx, templatePath, c, outputFormat, renderOpts)
+	if err != nil {
+		res.Err = fmt.Errorf("failed to render template: %w", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(res.Path), 0755); err != nil {
+		res.Err = fmt.Errorf("failed to create output directory: %w", err)
+		return
+	}
+	if err := os.WriteFile(res.Path, result, 0644); err != nil {
+		res.Err = fmt.Errorf("failed to write output: %w", err)
+	}
+}
+
+// printIterationSummary prints one row per variant and returns the number of
+// successful and failed variants.
+func printIterationSummary(results []iterationResult) (succ, failed int) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VARIANT\tSTATUS\tDURATION\tOUTPUT")
+	for _, res := range results {
+		status, output := "✅ ok", res.Path
+		switch {
+		case res.Err != nil:
+			status, output = "❌ failed", res.Err.Error()
+			failed++
+		case res.Skipped:
+			status, output = "⏭️  skipped", "-"
+		default:
+			if res.Dropped > 0 {
+				output = fmt.Sprintf("%s (dropped %d items)", output, res.Dropped)
+			}
+			succ++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", res.Name, status, res.Duration.Round(time.Millisecond), output)
+	}
+	w.Flush()
+	return succ, failed
+}
@@ -0,0 +1,74 @@
+// lint.go
+package main
+
+import (
+	"cvforge/engine"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newLintCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check template directives against the data",
+		Long: `Check every value-of, repeat-for, if-exists and attr-* directive in the
+template against the data, resolving paths the same way rendering does.
+Reports paths that resolve to nothing, malformed directives and data keys
+the template never uses. Exits with an error when any error is found.`,
+		RunE: runLint,
+	}
+
+	cmd.Flags().StringVarP(&templatePath, "template", "t", "", "Path to HTML template file (required)")
+	cmd.Flags().StringVarP(&dataPath, "data", "d", "", "Path to JSON (or YAML) data file (required)")
+	cmd.Flags().StringVar(&schemaPath, "schema", "", "JSON Schema file the data must match")
+	cmd.Flags().StringSliceVar(&tags, "tags", []string{}, "Tags to filter data before checking")
+	cmd.Flags().StringVar(&tagsExpr, "tags-expr", "", "Tag expression to filter data before checking")
+	cmd.Flags().StringVar(&profile, "profile", "", "Tag profile from the data file to filter data before checking")
+	cmd.Flags().BoolVar(&inheritTags, "inherit-tags", false, "Give untagged values the tags of their parent before filtering")
+	cmd.MarkFlagsMutuallyExclusive("tags", "tags-expr", "profile")
+
+	cmd.MarkFlagRequired("template")
+	cmd.MarkFlagRequired("data")
+	return cmd
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	if err := validateInputs(); err != nil {
+		return err
+	}
+
+	data, profiles, err := loadData(schemaPath, dataPath)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+	data, _, err = tagFilter{Tags: tags, Expr: tagsExpr, Profile: profile, Inherit: inheritTags}.apply(data, profiles)
+	if err != nil {
+		return err
+	}
+
+	issues, err := engine.Lint(templatePath, data)
+	if err != nil {
+		return err
+	}
+
+	errs, warnings := 0, 0
+	for _, issue := range issues {
+		if issue.Line > 0 {
+			fmt.Printf("%s:%s\n", templatePath, issue)
+		} else {
+			fmt.Printf("%s: %s\n", dataPath, issue)
+		}
+		if issue.Severity == engine.LintError {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+
+	if errs > 0 {
+		return fmt.Errorf("%d errors, %d warnings", errs, warnings)
+	}
+	fmt.Printf("✅ No errors (%d warnings)\n", warnings)
+	return nil
+}
@@ -2,28 +2,53 @@
 package main
 
 import (
+	"context"
+	"cvforge/config"
 	"cvforge/engine"
+	"cvforge/schema"
 	"cvforge/types"
-	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
 const version = "1.0.0"
 
 var (
-	templatePath string
-	dataPath     string
-	outputPath   string
-	format       string
-	verbose      bool
-	iterate      bool
-	tags         []string
+	templatePath  string
+	dataPath      string
+	outputPath    string
+	assetsPath    string
+	format        string
+	verbose       bool
+	iterate       string
+	jobs          int
+	tags          []string
+	tagsExpr      string
+	profile       string
+	groups        []string
+	outputPattern string
+	inheritTags   bool
+	maxPages      int
+	fitPages      int
+	minScale      float64
+	configPath    string
+	schemaPath    string
+	watch         bool
+
+	// PDF flags; unset flags fall back to the config file
+	paper          string
+	margin         string
+	landscape      bool
+	headerTemplate string
+	footerTemplate string
 )
 
 func main() {
@@ -40,24 +65,65 @@ and structured data into beautiful PDFs or HTML documents.`,
 	rootCmd.Flags().StringVarP(&templatePath, "template", "t", "", "Path to HTML template file (required)")
 	rootCmd.Flags().StringVarP(&dataPath, "data", "d", "", "Path to JSON (or YAML) data file (required)")
 	rootCmd.Flags().StringVarP(&outputPath, "output", "o", "output.pdf", "Output file path")
+	rootCmd.Flags().StringVar(&assetsPath, "assets", "", "Extra directory to serve template assets (CSS, images, fonts) from")
 	rootCmd.Flags().StringVarP(&format, "format", "f", "pdf", "Output format: pdf or html")
 	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
-	rootCmd.Flags().BoolVar(&iterate, "iterate", false, "Enable iteration mode (mutually exclusive with --tags)")
+	rootCmd.Flags().StringVar(&iterate, "iterate", "", "Render one file per tag, or per profile, group or pair of tags with --iterate=profiles|groups|pairs (mutually exclusive with --tags)")
+	rootCmd.Flags().Lookup("iterate").NoOptDefVal = iterateTags
+	rootCmd.Flags().StringArrayVar(&groups, "group", nil, `Comma-separated tags rendered together with --iterate=groups (repeatable)`)
+	rootCmd.Flags().StringVar(&outputPattern, "output-pattern", defaultOutputPattern, "File name pattern for --iterate: {tag}, {ext} or a data path such as {name}")
+	rootCmd.Flags().IntVar(&maxPages, "max-pages", 0, "Drop the lowest priority list items until the PDF fits in this many pages")
+	rootCmd.Flags().IntVar(&fitPages, "fit-pages", 0, "Shrink the PDF, down to --min-scale, until it takes at most this many pages (shorter output is never scaled up)")
+	rootCmd.Flags().Float64Var(&minScale, "min-scale", engine.DefaultMinScale, "Smallest scale --fit-pages may shrink to")
+	rootCmd.Flags().IntVarP(&jobs, "jobs", "j", runtime.NumCPU(), "Number of variants rendered in parallel with --iterate")
 	rootCmd.Flags().StringSliceVar(&tags, "tags", []string{}, "Tags to filter data (mutually exclusive with --iterate)")
+	rootCmd.Flags().BoolVar(&inheritTags, "inherit-tags", false, "Give untagged values the tags of their parent before filtering")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "Tag profile from the data file's _profiles to filter data")
+	rootCmd.Flags().StringVar(&tagsExpr, "tags-expr", "", `Tag expression to filter data, e.g. "go & (azure | gcp) & !legacy"`)
+
+	rootCmd.Flags().StringVar(&schemaPath, "schema", "", "JSON Schema file the data must match (checked before rendering)")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to config file (default \"cvforge.yaml\" if present)")
+
+	// PDF flags
+	rootCmd.Flags().StringVar(&paper, "paper", "", "Paper size: a4, letter, legal, a3, a5, tabloid or WIDTHxHEIGHT (e.g. 210mmx297mm)")
+	rootCmd.Flags().StringVar(&margin, "margin", "", "Page margins, one to four CSS-style lengths (e.g. \"10mm\" or \"10mm 15mm\")")
+	rootCmd.Flags().BoolVar(&landscape, "landscape", false, "Print in landscape orientation")
+	rootCmd.Flags().StringVar(&headerTemplate, "header-template", "", "HTML file (or inline HTML) printed at the top of every page")
+	rootCmd.Flags().StringVar(&footerTemplate, "footer-template", "", "HTML file (or inline HTML) printed at the bottom of every page")
+
+	rootCmd.Flags().BoolVar(&watch, "watch", false, "Serve a live-reloading HTML preview instead of writing output (same as 'cvforge serve')")
+	rootCmd.Flags().StringVar(&serveAddr, "addr", "localhost:8080", "Address to serve the preview on with --watch")
+
+	rootCmd.AddCommand(newBuildCmd())
+	rootCmd.AddCommand(newServeCmd())
+	rootCmd.AddCommand(newLintCmd())
 
 	// Mark flags as mutually exclusive
 	rootCmd.MarkFlagsMutuallyExclusive("iterate", "tags")
+	rootCmd.MarkFlagsMutuallyExclusive("iterate", "tags-expr")
+	rootCmd.MarkFlagsMutuallyExclusive("iterate", "profile")
+	rootCmd.MarkFlagsMutuallyExclusive("tags", "tags-expr", "profile")
+	rootCmd.MarkFlagsMutuallyExclusive("iterate", "watch")
+	rootCmd.MarkFlagsMutuallyExclusive("max-pages", "fit-pages")
 
 	rootCmd.MarkFlagRequired("template")
 	rootCmd.MarkFlagRequired("data")
 
-	if err := rootCmd.Execute(); err != nil {
+	// Cancel in-flight renders on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
 func run(cmd *cobra.Command, args []string) error {
+	if watch {
+		return runServe(cmd.Context())
+	}
+
 	// Validate inputs
 	if err := validateInputs(); err != nil {
 		return err
@@ -73,7 +139,7 @@ func run(cmd *cobra.Command, args []string) error {
 	}
 
 	// Load data
-	data, err := loadData(dataPath)
+	data, profiles, err := loadData(schemaPath, dataPath)
 	if err != nil {
 		return fmt.Errorf("failed to load data: %w", err)
 	}
@@ -83,42 +149,71 @@ func run(cmd *cobra.Command, args []string) error {
 	}
 
 	// Determine output format
-	var outputFormat engine.OutputFormat
-	switch strings.ToLower(format) {
-	case "pdf":
-		outputFormat = engine.OutputPDF
-	case "html":
-		outputFormat = engine.OutputHTML
-	default:
-		return fmt.Errorf("invalid format: %s (use 'pdf' or 'html')", format)
+	outputFormat, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+
+	// Start the browser once and share it across every PDF we render
+	renderOpts := engine.RenderOptions{Warn: printWarning}
+	if assetsPath != "" {
+		renderOpts.AssetRoots = []string{assetsPath}
+	}
+	if maxPages > 0 && outputFormat != engine.OutputPDF {
+		return fmt.Errorf("--max-pages needs PDF output")
+	}
+	renderOpts.MaxPages = maxPages
+	if outputFormat == engine.OutputPDF {
+		pdfOpts, err := buildPDFOptions(cmd)
+		if err != nil {
+			return err
+		}
+		renderOpts.PDF = pdfOpts
+
+		tabs := 1
+		if iterate != "" {
+			tabs = jobs
+		}
+		renderer, err := engine.NewPDFRenderer(tabs)
+		if err != nil {
+			return fmt.Errorf("failed to start PDF renderer: %w", err)
+		}
+		defer renderer.Close()
+		renderOpts.Renderer = renderer
 	}
 
 	// Render template
 	if verbose {
 		fmt.Println("🔄 Rendering template...")
 	}
-	if iterate {
-		succ, errs := processIteration(outputPath, data, templatePath, outputFormat)
-		if len(errs) > 0 {
-			for _, err := range errs {
-				fmt.Printf("❌ %s\n", err)
-			}
-			return fmt.Errorf("failed to render template: %w", errs[0])
+	filter := tagFilter{Tags: tags, Expr: tagsExpr, Profile: profile, Inherit: inheritTags}
+	data, renderOpts.Filter, err = filter.apply(data, profiles)
+	if err != nil {
+		return err
+	}
+	if iterate != "" {
+		variants, err := iterationVariants(iterate, data, profiles, groups)
+		if err != nil {
+			return err
+		}
+		results, err := processIteration(cmd.Context(), outputPath, outputPattern, data, variants, templatePath, outputFormat, renderOpts, jobs)
+		if err != nil {
+			return err
+		}
+		succ, failed := printIterationSummary(results)
+		if failed > 0 {
+			return fmt.Errorf("%d of %d variants failed to render", failed, succ+failed)
 		}
 		fmt.Printf("✅ %d templates rendered successfully\n", succ)
 		return nil
 	}
-	if len(tags) > 0 {
-		var ok bool
-		data, ok = data.Filter(tags)
-		if !ok {
-			return fmt.Errorf("no data found for tags: %v", tags)
-		}
-	}
-	result, err := engine.Render(templatePath, data, outputFormat)
+	var dropped []types.ListItem
+	renderOpts.Dropped = func(items []types.ListItem) { dropped = items }
+	result, err := engine.Render(cmd.Context(), templatePath, data, outputFormat, renderOpts)
 	if err != nil {
 		return fmt.Errorf("failed to render template: %w", err)
 	}
+	printDropped(dropped, renderOpts.MaxPages)
 
 	if verbose {
 		fmt.Printf("✅ Template rendered successfully\n")
@@ -133,33 +228,90 @@ func run(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func processIteration(outputPath string, data types.CVBase, templatePath string, outputFormat engine.OutputFormat) (int, []error) {
-	succ:=0
-	if _, err := os.Stat(outputPath); os.IsNotExist(err) {
-		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-			return succ, []error{fmt.Errorf("failed to create output directory: %w", err)}
-		}
+// printWarning reports data the engine left out of the output
+func printWarning(message string) {
+	fmt.Fprintf(os.Stderr, "⚠️  %s\n", message)
+}
+
+// printDropped reports the list items --max-pages removed
+func printDropped(dropped []types.ListItem, maxPages int) {
+	if len(dropped) == 0 {
+		return
 	}
-	var errors []error
-	tags := data.GetEveryTag()
-	for _, tag := range tags {
-		c := data.Copy()
-		c, ok := c.Filter([]string{tag})
-		if !ok {
-			continue
-		}
-		result, err := engine.Render(templatePath, c, outputFormat)
-		if err != nil {
-			errors = append(errors, fmt.Errorf("failed to render template: %w", err))
+	fmt.Printf("✂️  Dropped %d items to fit %d pages:\n", len(dropped), maxPages)
+	for _, item := range dropped {
+		text := ""
+		if s, ok := item.Value.(types.CVForgeString); ok {
+			text = s.Value
+			if runes := []rune(text); len(runes) > 60 {
+				text = string(runes[:57]) + "..."
+			}
+			text = fmt.Sprintf(" %q", text)
 		}
-		path := fmt.Sprintf("%s/%s.%s", outputPath, tag, outputFormat)
-		if err := os.WriteFile(path, result, 0644); err != nil {
-			errors = append(errors, fmt.Errorf("failed to write output: %w", err))
+		fmt.Printf("   - %s (priority %g)%s\n", item.Path, item.Priority, text)
+	}
+}
+
+// buildPDFOptions starts from the A4 defaults, applies the config file and
+// then any PDF flags given on the command line
+func buildPDFOptions(cmd *cobra.Command) (*engine.PDFOptions, error) {
+	opts := engine.DefaultPDFOptions()
+	opts.PreferCSSPageSize = true
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		if err := cfg.PDF.Apply(&opts, cfg.Dir); err != nil {
+			return nil, fmt.Errorf("invalid pdf options in config: %w", err)
 		}
-		succ++
 	}
 
-	return succ, errors
+	var flagOpts config.PDF
+	flags := cmd.Flags()
+	if flags.Changed("paper") {
+		flagOpts.Paper = paper
+	}
+	if flags.Changed("margin") {
+		flagOpts.Margin = margin
+	}
+	if flags.Changed("landscape") {
+		flagOpts.Landscape = &landscape
+	}
+	if flags.Changed("header-template") {
+		flagOpts.HeaderTemplate = headerTemplate
+	}
+	if flags.Changed("footer-template") {
+		flagOpts.FooterTemplate = footerTemplate
+	}
+	if flags.Changed("fit-pages") {
+		flagOpts.FitPages = fitPages
+	}
+	if flags.Changed("min-scale") {
+		flagOpts.MinScale = minScale
+	}
+	if err := flagOpts.Apply(&opts, ""); err != nil {
+		return nil, err
+	}
+	return &opts, nil
+}
+
+// loadConfig loads --config, or cvforge.yaml from the working directory.
+// It returns nil when no config file is used.
+func loadConfig() (*config.Config, error) {
+	path := configPath
+	if path == "" {
+		var ok bool
+		if path, ok = config.Find(); !ok {
+			return nil, nil
+		}
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg, nil
 }
 
 func validateInputs() error {
@@ -168,11 +320,30 @@ func validateInputs() error {
 		return fmt.Errorf("template file not found: %s", templatePath)
 	}
 
+	// Check assets directory exists
+	if assetsPath != "" {
+		if stat, err := os.Stat(assetsPath); err != nil || !stat.IsDir() {
+			return fmt.Errorf("assets directory not found: %s", assetsPath)
+		}
+	}
+
 	// Check data exists
 	if _, err := os.Stat(dataPath); os.IsNotExist(err) {
 		return fmt.Errorf("data file not found: %s", dataPath)
 	}
 
+	if jobs < 1 {
+		return fmt.Errorf("invalid --jobs value: %d (must be at least 1)", jobs)
+	}
+
+	if maxPages < 0 {
+		return fmt.Errorf("invalid --max-pages value: %d", maxPages)
+	}
+
+	if iterate != "" && !slices.Contains(iterateModes, iterate) {
+		return fmt.Errorf("invalid --iterate value: %s (use %s)", iterate, strings.Join(iterateModes, ", "))
+	}
+
 	// Create output directory if needed
 	outputDir := filepath.Dir(outputPath)
 	if outputDir != "." && outputDir != "" {
@@ -184,23 +355,137 @@ func validateInputs() error {
 	return nil
 }
 
-func loadData(path string) (types.CVBase, error) {
-	fileData, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
+// loadData loads one or more data files. Later files are merged over
+// earlier ones, so a base file can be specialised per language or role.
+// The merged data is validated against the built-in schema, and against
+// the JSON Schema at schemaPath when set, before anything is rendered.
+// Tag profiles declared under _profiles are returned apart from the data.
+func loadData(schemaPath string, paths ...string) (types.CVBase, types.Profiles, error) {
+	var rawData any
+	docs := make([]*schema.Document, 0, len(paths))
+	for _, path := range paths {
+		fileData, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		doc, err := schema.Parse(path, fileData)
+		if err != nil {
+			return nil, nil, err
+		}
+		docs = append(docs, doc)
 
-	var rawData map[string]interface{}
-	if err := json.Unmarshal(fileData, &rawData); err != nil {
-		if err = yaml.Unmarshal(fileData, &rawData); err != nil {
-			return nil, err
+		// Decode keeping key order so maps render in the order they were written
+		fileRaw, err := types.DecodeJSON(fileData)
+		if err != nil {
+			if fileRaw, err = types.DecodeYAML(fileData); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", path, err)
+			}
 		}
+		rawData = types.MergeRaw(rawData, fileRaw)
 	}
+
+	if err := validateData(schema.Merge(docs...), schemaPath); err != nil {
+		return nil, nil, err
+	}
+
+	rawData, profiles, err := types.ExtractProfiles(rawData)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var cv types.CVBase
 	cv, ok := types.UnmarshalCVBase(rawData, types.DefaultCVTagInfo())
 	if !ok {
-		return nil, fmt.Errorf("failed to unmarshal data")
+		return nil, nil, fmt.Errorf("failed to unmarshal data")
+	}
+	return cv, profiles, nil
+}
+
+// validateData checks doc against the built-in schema and the user schema
+// at schemaPath, reporting every violation at once
+func validateData(doc *schema.Document, schemaPath string) error {
+	violations := schema.Builtin().Validate(doc)
+	if schemaPath != "" {
+		content, err := os.ReadFile(schemaPath)
+		if err != nil {
+			return fmt.Errorf("failed to read schema: %w", err)
+		}
+		userSchema, err := schema.Compile(content)
+		if err != nil {
+			return err
+		}
+		violations = append(violations, userSchema.Validate(doc)...)
+	}
+	if len(violations) > 0 {
+		schema.SortViolations(violations)
+		return &schema.ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// tagFilter narrows the data by a --tags list, a --tags-expr expression or
+// a --profile declared in the data file. At most one of them is set.
+// Inherit passes parent tags down to untagged children first.
+type tagFilter struct {
+	Tags    []string
+	Expr    string
+	Profile string
+	Inherit bool
+}
+
+// apply keeps the parts of data matching f and returns the expression it
+// filtered with. Data is returned unfiltered, with a nil expression, when
+// no tags are given.
+func (f tagFilter) apply(data types.CVBase, profiles types.Profiles) (types.CVBase, types.TagExpr, error) {
+	set := 0
+	for _, given := range []bool{len(f.Tags) > 0, f.Expr != "", f.Profile != ""} {
+		if given {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, nil, fmt.Errorf("only one of tags, tags-expr and profile can be used")
+	}
+
+	if f.Inherit {
+		data = types.InheritTags(data)
+	}
+
+	var expr types.TagExpr
+	switch {
+	case f.Expr != "":
+		var err error
+		if expr, err = types.ParseTagExpr(f.Expr); err != nil {
+			return nil, nil, err
+		}
+	case len(f.Tags) > 0:
+		expr = types.AnyTag(f.Tags...)
+	case f.Profile != "":
+		p, ok := profiles.Lookup(f.Profile)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown profile %q (data declares: %s)", f.Profile, strings.Join(profiles.Names(), ", "))
+		}
+		expr = p.Expr
+	default:
+		return data, nil, nil
+	}
+
+	data, ok := data.Filter(expr)
+	if !ok {
+		return nil, nil, fmt.Errorf("no data found for tags: %s", expr)
 	}
-	return cv, nil
+	return data, expr, nil
 }
 
+// parseFormat converts a --format value to an engine.OutputFormat
+func parseFormat(format string) (engine.OutputFormat, error) {
+	switch strings.ToLower(format) {
+	case "pdf":
+		return engine.OutputPDF, nil
+	case "html":
+		return engine.OutputHTML, nil
+	default:
+		return "", fmt.Errorf("invalid format: %s (use 'pdf' or 'html')", format)
+	}
+}
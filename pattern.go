@@ -0,0 +1,114 @@
+// pattern.go
+package main
+
+import (
+	"cvforge/engine"
+	"cvforge/types"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// defaultOutputPattern names --iterate files after their variant
+const defaultOutputPattern = "{tag}.{ext}"
+
+// variantPaths expands pattern for every variant below outputDir. {tag} is
+// the variant name and {ext} the output format; any other placeholder is a
+// data path such as {name}. Substituted values are sanitised so they are
+// safe as a single file name.
+func variantPaths(outputDir, pattern string, variants []variant, outputFormat engine.OutputFormat, data types.CVBase) ([]string, error) {
+	paths := make([]string, len(variants))
+	seen := make(map[string]string, len(variants))
+	for i, v := range variants {
+		name, err := expandOutputPattern(pattern, func(key string) (string, error) {
+			switch key {
+			case "tag":
+				return v.Name, nil
+			case "ext":
+				return string(outputFormat), nil
+			}
+			if value, ok := dataField(data, key); ok {
+				return value, nil
+			}
+			return "", fmt.Errorf("output pattern %q: unknown placeholder {%s}", pattern, key)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(outputDir, name)
+		if other, dup := seen[path]; dup {
+			return nil, fmt.Errorf("variants %q and %q would both be written to %s", other, v.Name, path)
+		}
+		seen[path] = v.Name
+		paths[i] = path
+	}
+	return paths, nil
+}
+
+// expandOutputPattern replaces every {key} in pattern with the sanitised
+// value lookup returns for it
+func expandOutputPattern(pattern string, lookup func(key string) (string, error)) (string, error) {
+	var sb strings.Builder
+	rest := pattern
+	for {
+		start := strings.Index(rest, "{")
+		if start == -1 {
+			sb.WriteString(rest)
+			break
+		}
+		end := strings.Index(rest[start:], "}")
+		if end == -1 {
+			return "", fmt.Errorf("output pattern %q: unbalanced braces", pattern)
+		}
+		end += start
+
+		value, err := lookup(strings.TrimSpace(rest[start+1 : end]))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(rest[:start])
+		sb.WriteString(sanitizeFileName(value))
+		rest = rest[end+1:]
+	}
+	return sb.String(), nil
+}
+
+// sanitizeFileName makes s safe as one path element: path separators and
+// characters that are reserved on common file systems become "-", and
+// leading dots are dropped so ".NET" does not turn into a hidden file.
+func sanitizeFileName(s string) string {
+	var sb strings.Builder
+	lastDash := false
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("._+@#", r) {
+			sb.WriteRune(r)
+			lastDash = false
+		} else if !lastDash {
+			sb.WriteByte('-')
+			lastDash = true
+		}
+	}
+	name := strings.Trim(sb.String(), "-.")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// dataField returns the string at a dotted path of the data, such as name
+func dataField(data types.CVBase, path string) (string, bool) {
+	current := data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(types.CVForgeMap)
+		if !ok {
+			return "", false
+		}
+		if current, ok = m.Value[part]; !ok {
+			return "", false
+		}
+	}
+	s, ok := current.(types.CVForgeString)
+	return s.Value, ok
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a parsed data file (or several merged ones) that keeps the
+// position of every value for error reporting
+type Document struct {
+	Root *yaml.Node
+
+	// files records which file each node came from
+	files map[*yaml.Node]string
+}
+
+// Parse parses a YAML or JSON data file. name is used in violation reports.
+func Parse(name string, data []byte) (*Document, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	d := &Document{files: make(map[*yaml.Node]string)}
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		d.Root = doc.Content[0]
+	}
+	d.record(d.Root, name)
+	return d, nil
+}
+
+func (d *Document) record(node *yaml.Node, name string) {
+	if node == nil {
+		return
+	}
+	if _, seen := d.files[node]; seen {
+		return
+	}
+	d.files[node] = name
+	for _, child := range node.Content {
+		d.record(child, name)
+	}
+}
+
+func (d *Document) fileOf(node *yaml.Node) string {
+	return d.files[node]
+}
+
+// Merge combines documents the same way data files are merged for
+// rendering: mappings merge key by key and later documents win. Nodes keep
+// the file and position they were parsed from.
+func Merge(docs ...*Document) *Document {
+	merged := &Document{files: make(map[*yaml.Node]string)}
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		for node, name := range doc.files {
+			merged.files[node] = name
+		}
+		merged.Root = merged.mergeNodes(merged.Root, doc.Root)
+	}
+	return merged
+}
+
+func (d *Document) mergeNodes(base, override *yaml.Node) *yaml.Node {
+	base, override = resolveAlias(base), resolveAlias(override)
+	if base == nil {
+		return override
+	}
+	if override == nil {
+		return base
+	}
+	if base.Kind != yaml.MappingNode || override.Kind != yaml.MappingNode {
+		return override
+	}
+
+	out := *base
+	out.Content = nil
+	d.files[&out] = d.files[base]
+
+	basePairs, overridePairs := mappingPairs(base), mappingPairs(override)
+	for _, p := range basePairs {
+		value := p.value
+		if o, found := findPair(overridePairs, p.key.Value); found {
+			value = d.mergeNodes(p.value, o.value)
+		}
+		out.Content = append(out.Content, p.key, value)
+	}
+	for _, o := range overridePairs {
+		if _, found := findPair(basePairs, o.key.Value); !found {
+			out.Content = append(out.Content, o.key, o.value)
+		}
+	}
+	return &out
+}
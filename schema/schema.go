@@ -0,0 +1,121 @@
+// Package schema validates CVForge data files against JSON Schema before
+// rendering, reporting every violation with its line and column.
+//
+// The validator covers the commonly used subset of JSON Schema (draft-07 to
+// 2020-12): type, enum, const, properties, required, additionalProperties,
+// patternProperties, items, min/max constraints, pattern, allOf, anyOf,
+// oneOf, not, if/then/else and local $ref. Unknown keywords are ignored.
+package schema
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed cvforge.schema.json
+var builtinSchema []byte
+
+// Schema is a compiled JSON Schema document
+type Schema struct {
+	root    any
+	regexps map[string]*regexp.Regexp
+}
+
+// Compile parses a JSON Schema written in JSON or YAML
+func Compile(data []byte) (*Schema, error) {
+	var root any
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	switch root.(type) {
+	case map[string]any, bool:
+	default:
+		return nil, fmt.Errorf("invalid schema: expected an object")
+	}
+
+	s := &Schema{root: root, regexps: make(map[string]*regexp.Regexp)}
+	if err := s.compilePatterns(root); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Builtin returns the schema describing the structure CVForge understands
+func Builtin() *Schema {
+	s, err := Compile(builtinSchema)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// compilePatterns compiles every pattern and patternProperties key up front
+// so a bad regular expression is reported once, as a schema error
+func (s *Schema) compilePatterns(node any) error {
+	switch n := node.(type) {
+	case map[string]any:
+		if p, ok := n["pattern"].(string); ok {
+			if err := s.addPattern(p); err != nil {
+				return err
+			}
+		}
+		if pp, ok := n["patternProperties"].(map[string]any); ok {
+			for p := range pp {
+				if err := s.addPattern(p); err != nil {
+					return err
+				}
+			}
+		}
+		for _, v := range n {
+			if err := s.compilePatterns(v); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, v := range n {
+			if err := s.compilePatterns(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) addPattern(p string) error {
+	if _, ok := s.regexps[p]; ok {
+		return nil
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return fmt.Errorf("invalid schema pattern %q: %w", p, err)
+	}
+	s.regexps[p] = re
+	return nil
+}
+
+// resolveRef follows a local reference such as "#/$defs/node"
+func (s *Schema) resolveRef(ref string) (any, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %q (only local references are supported)", ref)
+	}
+	current := s.root
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer == "" {
+		return current, nil
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolved $ref %q", ref)
+		}
+		if current, ok = m[part]; !ok {
+			return nil, fmt.Errorf("unresolved $ref %q", ref)
+		}
+	}
+	return current, nil
+}
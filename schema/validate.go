@@ -0,0 +1,517 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Violation is one place where a document does not match a schema
+type Violation struct {
+	File    string
+	Line    int
+	Column  int
+	Path    string // dot-notation, as used by template directives
+	Message string
+}
+
+func (v Violation) String() string {
+	loc := fmt.Sprintf("%d:%d", v.Line, v.Column)
+	if v.File != "" {
+		loc = v.File + ":" + loc
+	}
+	path := v.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Sprintf("%s: %s: %s", loc, path, v.Message)
+}
+
+// ValidationError reports every violation found in a document
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		lines[i] = "  " + v.String()
+	}
+	return fmt.Sprintf("data does not match schema (%d problems):\n%s", len(e.Violations), strings.Join(lines, "\n"))
+}
+
+// Validate checks doc against the schema and returns every violation, sorted
+// by file and position
+func (s *Schema) Validate(doc *Document) []Violation {
+	if doc == nil || doc.Root == nil {
+		return nil
+	}
+	v := &validator{schema: s, doc: doc}
+	violations := v.validate(s.root, doc.Root, "")
+	SortViolations(violations)
+	return violations
+}
+
+// SortViolations orders violations by file and position
+func SortViolations(violations []Violation) {
+	sort.SliceStable(violations, func(i, j int) bool {
+		a, b := violations[i], violations[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+type validator struct {
+	schema *Schema
+	doc    *Document
+}
+
+func (v *validator) violation(node *yaml.Node, path, format string, args ...any) Violation {
+	return Violation{
+		File:    v.doc.fileOf(node),
+		Line:    node.Line,
+		Column:  node.Column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (v *validator) validate(sch any, node *yaml.Node, path string) []Violation {
+	node = resolveAlias(node)
+
+	switch s := sch.(type) {
+	case bool:
+		if !s {
+			return []Violation{v.violation(node, path, "value is not allowed here")}
+		}
+		return nil
+	case map[string]any:
+		return v.validateObject(s, node, path)
+	}
+	return nil
+}
+
+func (v *validator) validateObject(s map[string]any, node *yaml.Node, path string) []Violation {
+	var out []Violation
+
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := v.schema.resolveRef(ref)
+		if err != nil {
+			return []Violation{v.violation(node, path, "%s", err)}
+		}
+		out = append(out, v.validate(target, node, path)...)
+	}
+
+	actual := jsonType(node)
+	if want, ok := s["type"]; ok && !typeMatches(want, node, actual) {
+		// Nothing else is meaningful once the type is wrong
+		return append(out, v.violation(node, path, "expected %s, got %s", describeType(want), actual))
+	}
+
+	if enum, ok := s["enum"].([]any); ok {
+		value := canonical(decodeNode(node))
+		found := false
+		for _, e := range enum {
+			if canonical(e) == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, v.violation(node, path, "value must be one of %s", canonical(enum)))
+		}
+	}
+	if c, ok := s["const"]; ok && canonical(c) != canonical(decodeNode(node)) {
+		out = append(out, v.violation(node, path, "value must be %s", canonical(c)))
+	}
+
+	switch actual {
+	case "string":
+		out = append(out, v.validateString(s, node, path)...)
+	case "integer", "number":
+		out = append(out, v.validateNumber(s, node, path)...)
+	case "array":
+		out = append(out, v.validateArray(s, node, path)...)
+	case "object":
+		out = append(out, v.validateMap(s, node, path)...)
+	}
+
+	out = append(out, v.validateCombinators(s, node, path)...)
+	return out
+}
+
+func (v *validator) validateString(s map[string]any, node *yaml.Node, path string) []Violation {
+	var out []Violation
+	length := utf8.RuneCountInString(node.Value)
+	if min, ok := number(s["minLength"]); ok && float64(length) < min {
+		out = append(out, v.violation(node, path, "must be at least %v characters", min))
+	}
+	if max, ok := number(s["maxLength"]); ok && float64(length) > max {
+		out = append(out, v.violation(node, path, "must be at most %v characters", max))
+	}
+	if p, ok := s["pattern"].(string); ok {
+		if re := v.schema.regexps[p]; re != nil && !re.MatchString(node.Value) {
+			out = append(out, v.violation(node, path, "must match pattern %q", p))
+		}
+	}
+	return out
+}
+
+func (v *validator) validateNumber(s map[string]any, node *yaml.Node, path string) []Violation {
+	var out []Violation
+	value, err := strconv.ParseFloat(node.Value, 64)
+	if err != nil {
+		return nil
+	}
+	if min, ok := number(s["minimum"]); ok && value < min {
+		out = append(out, v.violation(node, path, "must be at least %v", min))
+	}
+	if max, ok := number(s["maximum"]); ok && value > max {
+		out = append(out, v.violation(node, path, "must be at most %v", max))
+	}
+	if min, ok := number(s["exclusiveMinimum"]); ok && value <= min {
+		out = append(out, v.violation(node, path, "must be greater than %v", min))
+	}
+	if max, ok := number(s["exclusiveMaximum"]); ok && value >= max {
+		out = append(out, v.violation(node, path, "must be less than %v", max))
+	}
+	return out
+}
+
+func (v *validator) validateArray(s map[string]any, node *yaml.Node, path string) []Violation {
+	var out []Violation
+	items := node.Content
+
+	if min, ok := number(s["minItems"]); ok && float64(len(items)) < min {
+		out = append(out, v.violation(node, path, "must have at least %v items", min))
+	}
+	if max, ok := number(s["maxItems"]); ok && float64(len(items)) > max {
+		out = append(out, v.violation(node, path, "must have at most %v items", max))
+	}
+	if unique, _ := s["uniqueItems"].(bool); unique {
+		seen := make(map[string]bool)
+		for i, item := range items {
+			key := canonical(decodeNode(item))
+			if seen[key] {
+				out = append(out, v.violation(item, joinPath(path, strconv.Itoa(i)), "duplicate item"))
+			}
+			seen[key] = true
+		}
+	}
+
+	// prefixItems (2020-12) or an items array (draft-07) validate by position
+	prefix, _ := s["prefixItems"].([]any)
+	rest, hasRest := s["items"]
+	if tuple, ok := rest.([]any); ok {
+		prefix, rest = tuple, s["additionalItems"]
+		_, hasRest = s["additionalItems"]
+	}
+	for i, item := range items {
+		itemPath := joinPath(path, strconv.Itoa(i))
+		switch {
+		case i < len(prefix):
+			out = append(out, v.validate(prefix[i], item, itemPath)...)
+		case hasRest:
+			out = append(out, v.validate(rest, item, itemPath)...)
+		}
+	}
+	return out
+}
+
+func (v *validator) validateMap(s map[string]any, node *yaml.Node, path string) []Violation {
+	var out []Violation
+	pairs := mappingPairs(node)
+
+	if min, ok := number(s["minProperties"]); ok && float64(len(pairs)) < min {
+		out = append(out, v.violation(node, path, "must have at least %v properties", min))
+	}
+	if max, ok := number(s["maxProperties"]); ok && float64(len(pairs)) > max {
+		out = append(out, v.violation(node, path, "must have at most %v properties", max))
+	}
+
+	if required, ok := s["required"].([]any); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, found := findPair(pairs, name); !found {
+				out = append(out, v.violation(node, path, "missing required property %q", name))
+			}
+		}
+	}
+
+	properties, _ := s["properties"].(map[string]any)
+	patterns, _ := s["patternProperties"].(map[string]any)
+	additional, hasAdditional := s["additionalProperties"]
+
+	for _, p := range pairs {
+		key := p.key.Value
+		keyPath := joinPath(path, key)
+		matched := false
+
+		if sch, ok := properties[key]; ok {
+			matched = true
+			out = append(out, v.validate(sch, p.value, keyPath)...)
+		}
+		for pattern, sch := range patterns {
+			if re := v.schema.regexps[pattern]; re != nil && re.MatchString(key) {
+				matched = true
+				out = append(out, v.validate(sch, p.value, keyPath)...)
+			}
+		}
+		if matched || !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok && !allowed {
+			out = append(out, v.violation(p.key, keyPath, "unknown property %q", key))
+			continue
+		}
+		out = append(out, v.validate(additional, p.value, keyPath)...)
+	}
+	return out
+}
+
+func (v *validator) validateCombinators(s map[string]any, node *yaml.Node, path string) []Violation {
+	var out []Violation
+
+	if all, ok := s["allOf"].([]any); ok {
+		for _, sch := range all {
+			out = append(out, v.validate(sch, node, path)...)
+		}
+	}
+
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		if best, matches := v.matchBranches(anyOf, node, path); matches == 0 {
+			out = append(out, best...)
+		}
+	}
+
+	if oneOf, ok := s["oneOf"].([]any); ok {
+		best, matches := v.matchBranches(oneOf, node, path)
+		switch {
+		case matches == 0:
+			out = append(out, best...)
+		case matches > 1:
+			out = append(out, v.violation(node, path, "matches %d schemas, expected exactly one", matches))
+		}
+	}
+
+	if not, ok := s["not"]; ok && len(v.validate(not, node, path)) == 0 {
+		out = append(out, v.violation(node, path, "value matches a schema it must not match"))
+	}
+
+	if cond, ok := s["if"]; ok {
+		if len(v.validate(cond, node, path)) == 0 {
+			if then, ok := s["then"]; ok {
+				out = append(out, v.validate(then, node, path)...)
+			}
+		} else if els, ok := s["else"]; ok {
+			out = append(out, v.validate(els, node, path)...)
+		}
+	}
+	return out
+}
+
+// matchBranches counts the branches node satisfies. When none match it
+// returns the violations of the closest branch, which are usually the
+// most helpful to show.
+func (v *validator) matchBranches(branches []any, node *yaml.Node, path string) ([]Violation, int) {
+	var best []Violation
+	matches := 0
+	for _, sch := range branches {
+		violations := v.validate(sch, node, path)
+		if len(violations) == 0 {
+			matches++
+			continue
+		}
+		if best == nil || len(violations) < len(best) {
+			best = violations
+		}
+	}
+	return best, matches
+}
+
+type pair struct {
+	key, value *yaml.Node
+}
+
+// mappingPairs returns the entries of a mapping node, expanding "<<" merge
+// keys the same way the YAML decoder does
+func mappingPairs(node *yaml.Node) []pair {
+	var pairs, merged []pair
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveAlias(node.Content[i+1])
+		if key.ShortTag() != "!!merge" {
+			pairs = append(pairs, pair{key, value})
+			continue
+		}
+		sources := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			sources = value.Content
+		}
+		for _, src := range sources {
+			if src = resolveAlias(src); src.Kind == yaml.MappingNode {
+				merged = append(merged, mappingPairs(src)...)
+			}
+		}
+	}
+	for _, m := range merged {
+		if _, found := findPair(pairs, m.key.Value); !found {
+			pairs = append(pairs, m)
+		}
+	}
+	return pairs
+}
+
+func findPair(pairs []pair, key string) (pair, bool) {
+	for _, p := range pairs {
+		if p.key.Value == key {
+			return p, true
+		}
+	}
+	return pair{}, false
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// jsonType returns the JSON Schema type name of a node
+func jsonType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch node.ShortTag() {
+	case "!!null":
+		return "null"
+	case "!!bool":
+		return "boolean"
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	}
+	return "string"
+}
+
+func typeMatches(want any, node *yaml.Node, actual string) bool {
+	var names []string
+	switch w := want.(type) {
+	case string:
+		names = []string{w}
+	case []any:
+		for _, n := range w {
+			if s, ok := n.(string); ok {
+				names = append(names, s)
+			}
+		}
+	}
+	for _, name := range names {
+		switch {
+		case name == actual:
+			return true
+		case name == "number" && actual == "integer":
+			return true
+		case name == "integer" && actual == "number":
+			if f, err := strconv.ParseFloat(node.Value, 64); err == nil && f == math.Trunc(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func describeType(want any) string {
+	if list, ok := want.([]any); ok {
+		names := make([]string, len(list))
+		for i, n := range list {
+			names[i] = fmt.Sprint(n)
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(want)
+}
+
+func decodeNode(node *yaml.Node) any {
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return node.Value
+	}
+	return value
+}
+
+// canonical renders a value so equal values compare equal regardless of
+// whether they came from the schema or the document
+func canonical(value any) string {
+	b, err := json.Marshal(normalize(value))
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
+
+func normalize(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = normalize(item)
+		}
+		return out
+	case map[any]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[fmt.Sprint(k)] = normalize(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = normalize(item)
+		}
+		return out
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	}
+	return value
+}
+
+func number(value any) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func joinPath(path, part string) string {
+	if path == "" {
+		return part
+	}
+	return path + "." + part
+}
@@ -0,0 +1,313 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		data   string
+		want   []string
+	}{
+		{
+			name:   "type mismatch",
+			schema: `{type: object, properties: {name: {type: string}}}`,
+			data:   "name:\n  - a\n",
+			want:   []string{"2:3: name: expected string, got array"},
+		},
+		{
+			name:   "integer accepts whole numbers only",
+			schema: `{type: integer}`,
+			data:   "1.5",
+			want:   []string{"1:1: (root): expected integer, got number"},
+		},
+		{
+			name:   "number accepts integers",
+			schema: `{type: number}`,
+			data:   "3",
+		},
+		{
+			name:   "type list",
+			schema: `{type: [string, "null"]}`,
+			data:   "~",
+		},
+		{
+			name:   "quoted number is a string",
+			schema: `{type: integer}`,
+			data:   `"3"`,
+			want:   []string{"1:1: (root): expected integer, got string"},
+		},
+		{
+			name:   "required and unknown properties",
+			schema: `{type: object, required: [name], properties: {email: {type: string}}, additionalProperties: false}`,
+			data:   "email: a@b.c\nnmae: Ada\n",
+			want: []string{
+				`1:1: (root): missing required property "name"`,
+				`2:1: nmae: unknown property "nmae"`,
+			},
+		},
+		{
+			name:   "wrong type stops other checks",
+			schema: `{type: string, minLength: 3, enum: [abc]}`,
+			data:   "12",
+			want:   []string{"1:1: (root): expected string, got integer"},
+		},
+		{
+			name:   "enum and const",
+			schema: `{type: object, properties: {level: {enum: [junior, senior]}, version: {const: 1}}}`,
+			data:   "level: lead\nversion: 2\n",
+			want: []string{
+				`1:8: level: value must be one of ["junior","senior"]`,
+				`2:10: version: value must be 1`,
+			},
+		},
+		{
+			name:   "string constraints",
+			schema: `{type: array, items: {type: string, minLength: 2, maxLength: 4, pattern: "^[a-z]+$"}}`,
+			data:   "[a, abcde, Ab]",
+			want: []string{
+				"1:2: 0: must be at least 2 characters",
+				"1:5: 1: must be at most 4 characters",
+				"1:12: 2: must match pattern \"^[a-z]+$\"",
+			},
+		},
+		{
+			name:   "number constraints",
+			schema: `{type: array, items: {minimum: 1, exclusiveMaximum: 5}}`,
+			data:   "[0, 3, 5]",
+			want: []string{
+				"1:2: 0: must be at least 1",
+				"1:8: 2: must be less than 5",
+			},
+		},
+		{
+			name:   "array constraints",
+			schema: `{type: array, maxItems: 2, uniqueItems: true}`,
+			data:   "- a\n- b\n- a\n",
+			want: []string{
+				"1:1: (root): must have at most 2 items",
+				"3:3: 2: duplicate item",
+			},
+		},
+		{
+			name:   "tuple items",
+			schema: `{prefixItems: [{type: string}], items: {type: integer}}`,
+			data:   "[1, 2, x]",
+			want: []string{
+				"1:2: 0: expected string, got integer",
+				"1:8: 2: expected integer, got string",
+			},
+		},
+		{
+			name:   "pattern properties and additional schema",
+			schema: `{patternProperties: {"^x-": {type: string}}, additionalProperties: {type: integer}}`,
+			data:   "x-a: 1\ncount: many\n",
+			want: []string{
+				"1:6: x-a: expected string, got integer",
+				"2:8: count: expected integer, got string",
+			},
+		},
+		{
+			name:   "allOf reports every branch",
+			schema: `{allOf: [{minLength: 3}, {pattern: "^a"}]}`,
+			data:   "b",
+			want: []string{
+				"1:1: (root): must be at least 3 characters",
+				`1:1: (root): must match pattern "^a"`,
+			},
+		},
+		{
+			name:   "anyOf reports the closest branch",
+			schema: `{anyOf: [{minLength: 5, pattern: "^x"}, {minLength: 3}]}`,
+			data:   "ab",
+			want:   []string{"1:1: (root): must be at least 3 characters"},
+		},
+		{
+			name:   "anyOf with one match",
+			schema: `{anyOf: [{type: integer}, {type: string}]}`,
+			data:   "ab",
+		},
+		{
+			name:   "oneOf with several matches",
+			schema: `{oneOf: [{type: string}, {minLength: 1}]}`,
+			data:   "ab",
+			want:   []string{"1:1: (root): matches 2 schemas, expected exactly one"},
+		},
+		{
+			name:   "not",
+			schema: `{not: {type: string}}`,
+			data:   "ab",
+			want:   []string{"1:1: (root): value matches a schema it must not match"},
+		},
+		{
+			name:   "if then else",
+			schema: `{if: {type: string}, then: {minLength: 2}, else: {minimum: 10}}`,
+			data:   "[a, 5]",
+		},
+		{
+			name:   "if then else in items",
+			schema: `{items: {if: {type: string}, then: {minLength: 2}, else: {minimum: 10}}}`,
+			data:   "[a, 5]",
+			want: []string{
+				"1:2: 0: must be at least 2 characters",
+				"1:5: 1: must be at least 10",
+			},
+		},
+		{
+			name:   "local ref",
+			schema: `{$defs: {name: {type: string}}, properties: {a: {$ref: "#/$defs/name"}}}`,
+			data:   "a: 1",
+			want:   []string{"1:4: a: expected string, got integer"},
+		},
+		{
+			name:   "escaped ref",
+			schema: `{$defs: {"a/b": {type: string}}, properties: {a: {$ref: "#/$defs/a~1b"}}}`,
+			data:   "a: 1",
+			want:   []string{"1:4: a: expected string, got integer"},
+		},
+		{
+			name:   "unresolved ref",
+			schema: `{properties: {a: {$ref: "#/$defs/missing"}}}`,
+			data:   "a: 1",
+			want:   []string{`1:4: a: unresolved $ref "#/$defs/missing"`},
+		},
+		{
+			name:   "remote ref",
+			schema: `{$ref: "other.json#/a"}`,
+			data:   "a: 1",
+			want:   []string{`1:1: (root): unsupported $ref "other.json#/a" (only local references are supported)`},
+		},
+		{
+			name:   "false schema",
+			schema: `{properties: {a: false}}`,
+			data:   "a: 1",
+			want:   []string{"1:4: a: value is not allowed here"},
+		},
+		{
+			name:   "nested paths",
+			schema: `{properties: {jobs: {items: {properties: {tags: {items: {type: string}}}}}}}`,
+			data:   "jobs:\n  - tags: [go, 1]\n",
+			want:   []string{"2:16: jobs.0.tags.1: expected string, got integer"},
+		},
+		{
+			name:   "alias",
+			schema: `{properties: {b: {type: string}}}`,
+			data:   "a: &x [1]\nb: *x\n",
+			want:   []string{"1:4: b: expected string, got array"},
+		},
+		{
+			name:   "unknown keywords are ignored",
+			schema: `{type: string, format: email, title: Name}`,
+			data:   "ab",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Compile([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			doc, err := Parse("", []byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			var got []string
+			for _, v := range s.Validate(doc) {
+				got = append(got, v.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("violations:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{"malformed yaml", "{type: [string", "invalid schema:"},
+		{"not an object", "[1, 2]", "invalid schema: expected an object"},
+		{"scalar", "string", "invalid schema: expected an object"},
+		{"bad pattern", `{pattern: "a("}`, `invalid schema pattern "a("`},
+		{"bad pattern property", `{patternProperties: {"[": {}}}`, `invalid schema pattern "["`},
+		{"bad nested pattern", `{items: [{pattern: "*"}]}`, `invalid schema pattern "*"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(tt.schema))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("Compile(%q) error = %v, want prefix %q", tt.schema, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Compile([]byte("true")); err != nil {
+		t.Errorf("Compile(true) = %v, want a schema that accepts everything", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"unclosed flow", "a: [1, 2", "cv.yaml: yaml: line 1:"},
+		{"bad indentation", "a:\n  b: 1\n c: 2\n", "cv.yaml: yaml: line 2:"},
+		{"tab indentation", "a:\n\tb: 1\n", "cv.yaml: yaml: line 2:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("cv.yaml", []byte(tt.data))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("Parse(%q) error = %v, want prefix %q", tt.data, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergePositions(t *testing.T) {
+	base, err := Parse("base.yaml", []byte("name: Ada\nage: x\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	override, err := Parse("en.yaml", []byte("title: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Compile([]byte(`{additionalProperties: {type: string}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, v := range s.Validate(Merge(base, override)) {
+		got = append(got, v.String())
+	}
+	want := []string{"en.yaml:1:8: title: expected string, got integer"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("violations = %q, want %q", got, want)
+	}
+}
+
+func TestBuiltinAcceptsExample(t *testing.T) {
+	data := `
+name: Ada
+jobs:
+  - company: Acme
+    tags: [go]
+`
+	doc, err := Parse("cv.yaml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if violations := Builtin().Validate(doc); len(violations) != 0 {
+		t.Errorf("Builtin().Validate = %v, want none", violations)
+	}
+}
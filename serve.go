@@ -0,0 +1,293 @@
+// serve.go
+package main
+
+import (
+	"context"
+	"cvforge/engine"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// eventsPath is the SSE endpoint the preview page listens on
+	eventsPath = "/_cvforge/events"
+	// pollInterval is how often watched files are checked for changes
+	pollInterval = 500 * time.Millisecond
+)
+
+var serveAddr string
+
+// reloadScript reloads the preview page whenever the server re-renders
+const reloadScript = `<script>
+(function () {
+  var events = new EventSource("` + eventsPath + `");
+  events.addEventListener("reload", function () { location.reload(); });
+})();
+</script>`
+
+func newServeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Preview a template in the browser, re-rendering on every change",
+		Long: `Serve the rendered HTML on localhost and watch the template, its assets and
+the data file. The page reloads itself after every change; render errors are
+shown as an overlay instead of stopping the server.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServe(cmd.Context())
+		},
+	}
+
+	cmd.Flags().StringVarP(&templatePath, "template", "t", "", "Path to HTML template file (required)")
+	cmd.Flags().StringVarP(&dataPath, "data", "d", "", "Path to JSON (or YAML) data file (required)")
+	cmd.Flags().StringVar(&assetsPath, "assets", "", "Extra directory to serve template assets (CSS, images, fonts) from")
+	cmd.Flags().StringSliceVar(&tags, "tags", []string{}, "Tags to filter data")
+	cmd.Flags().StringVar(&tagsExpr, "tags-expr", "", "Tag expression to filter data")
+	cmd.Flags().StringVar(&profile, "profile", "", "Tag profile from the data file to filter data")
+	cmd.Flags().BoolVar(&inheritTags, "inherit-tags", false, "Give untagged values the tags of their parent before filtering")
+	cmd.MarkFlagsMutuallyExclusive("tags", "tags-expr", "profile")
+	cmd.Flags().StringVar(&schemaPath, "schema", "", "JSON Schema file the data must match")
+	cmd.Flags().StringVar(&serveAddr, "addr", "localhost:8080", "Address to serve the preview on")
+
+	cmd.MarkFlagRequired("template")
+	cmd.MarkFlagRequired("data")
+	return cmd
+}
+
+// runServe starts the preview server and blocks until ctx is cancelled
+func runServe(ctx context.Context) error {
+	if err := validateInputs(); err != nil {
+		return err
+	}
+
+	srv := &previewServer{
+		templatePath: templatePath,
+		dataPath:     dataPath,
+		schemaPath:   schemaPath,
+		filter:       tagFilter{Tags: tags, Expr: tagsExpr, Profile: profile, Inherit: inheritTags},
+		clients:      make(map[chan struct{}]struct{}),
+	}
+	srv.assetRoots = []string{filepath.Dir(templatePath)}
+	if assetsPath != "" {
+		srv.assetRoots = append(srv.assetRoots, assetsPath)
+	}
+	srv.render(ctx)
+
+	httpSrv := &http.Server{
+		Addr:        serveAddr,
+		Handler:     srv,
+		BaseContext: func(_ net.Listener) context.Context { return ctx },
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- httpSrv.ListenAndServe() }()
+	go srv.watch(ctx)
+
+	fmt.Printf("👀 Watching %s and %s\n", templatePath, dataPath)
+	fmt.Printf("🌐 Preview on http://%s (Ctrl-C to stop)\n", serveAddr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpSrv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// previewServer holds the latest render and the pages waiting for reloads
+type previewServer struct {
+	templatePath string
+	dataPath     string
+	schemaPath   string
+	filter       tagFilter
+	assetRoots   []string
+
+	mu      sync.Mutex
+	html    string
+	lastErr error
+	clients map[chan struct{}]struct{}
+}
+
+// render re-renders the template, keeping the last good output on failure,
+// and tells every open page to reload
+func (s *previewServer) render(ctx context.Context) {
+	content, err := s.renderHTML(ctx)
+
+	s.mu.Lock()
+	s.lastErr = err
+	if err == nil {
+		s.html = content
+	}
+	for client := range s.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+		}
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		fmt.Printf("❌ %s\n", err)
+	} else {
+		fmt.Printf("🔄 Rendered at %s\n", time.Now().Format("15:04:05"))
+	}
+}
+
+func (s *previewServer) renderHTML(ctx context.Context) (string, error) {
+	data, profiles, err := loadData(s.schemaPath, s.dataPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to load data: %w", err)
+	}
+	data, filter, err := s.filter.apply(data, profiles)
+	if err != nil {
+		return "", err
+	}
+	result, err := engine.Render(ctx, s.templatePath, data, engine.OutputHTML, engine.RenderOptions{Filter: filter, Warn: printWarning})
+	if err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return string(result), nil
+}
+
+func (s *previewServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		s.servePage(w)
+	case eventsPath:
+		s.serveEvents(w, r)
+	default:
+		engine.AssetHandler(s.assetRoots...).ServeHTTP(w, r)
+	}
+}
+
+// servePage writes the latest render with the reload script, adding an
+// error overlay when the last render failed
+func (s *previewServer) servePage(w http.ResponseWriter) {
+	s.mu.Lock()
+	page, lastErr := s.html, s.lastErr
+	s.mu.Unlock()
+
+	inject := reloadScript
+	if lastErr != nil {
+		inject = errorOverlay(lastErr) + inject
+	}
+	if idx := strings.LastIndex(strings.ToLower(page), "</body>"); idx != -1 {
+		page = page[:idx] + inject + page[idx:]
+	} else {
+		page += inject
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprint(w, page)
+}
+
+// serveEvents streams a reload event to the page after every render
+func (s *previewServer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.clients[client] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-client:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// watch polls the template, the data file and the asset directories, and
+// re-renders when any of them changes
+func (s *previewServer) watch(ctx context.Context) {
+	last := s.fingerprint()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if current := s.fingerprint(); current != last {
+				last = current
+				s.render(ctx)
+			}
+		}
+	}
+}
+
+// fingerprint summarises the size and modification time of every watched
+// file; it changes whenever a file is added, removed or modified
+func (s *previewServer) fingerprint() string {
+	var sb strings.Builder
+	add := func(path string, info fs.FileInfo) {
+		fmt.Fprintf(&sb, "%s|%d|%d\n", path, info.Size(), info.ModTime().UnixNano())
+	}
+
+	for _, path := range []string{s.templatePath, s.dataPath} {
+		if info, err := os.Stat(path); err == nil {
+			add(path, info)
+		}
+	}
+	for _, root := range s.assetRoots {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				add(path, info)
+			}
+			return nil
+		})
+	}
+	return sb.String()
+}
+
+// errorOverlay renders err as a fixed banner over the last good page
+func errorOverlay(err error) string {
+	return `<div style="position:fixed;inset:0;z-index:2147483647;background:rgba(20,20,20,.85);` +
+		`color:#fff;font:14px/1.5 monospace;padding:32px;overflow:auto">` +
+		`<strong style="color:#ff6b6b">CVForge render error</strong>` +
+		`<pre style="white-space:pre-wrap">` + html.EscapeString(err.Error()) + `</pre></div>`
+}
@@ -2,7 +2,7 @@ package types
 
 
 type CVBase interface{
-	Filter(tags []string) (data CVBase, passed bool)
+	Filter(expr TagExpr) (data CVBase, passed bool)
 	GetEveryTag() []string
 	Copy() CVBase
 }
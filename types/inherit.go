@@ -0,0 +1,39 @@
+package types
+
+import "slices"
+
+// InheritTags returns a copy of cv where every node without tags of its own
+// carries the tags of its nearest tagged ancestor, so nested values such as
+// responsibility bullets belong to the entry they sit in. Nodes that declare
+// tags keep them.
+func InheritTags(cv CVBase) CVBase {
+	return inheritTags(cv.Copy(), nil)
+}
+
+func inheritTags(cv CVBase, parent []string) CVBase {
+	switch v := cv.(type) {
+	case CVForgeString:
+		v.Tags = ownOrInherited(v.Tags, parent)
+		return v
+	case CVForgeSlice:
+		v.Tags = ownOrInherited(v.Tags, parent)
+		for i := range v.Value {
+			v.Value[i] = inheritTags(v.Value[i], v.Tags)
+		}
+		return v
+	case CVForgeMap:
+		v.Tags = ownOrInherited(v.Tags, parent)
+		for _, k := range v.OrderedKeys() {
+			v.Value[k] = inheritTags(v.Value[k], v.Tags)
+		}
+		return v
+	}
+	return cv
+}
+
+func ownOrInherited(own, parent []string) []string {
+	if len(own) > 0 {
+		return own
+	}
+	return slices.Clone(parent)
+}
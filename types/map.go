@@ -9,6 +9,8 @@ import (
 type CVForgeMap struct {
 	CVTagInfo
 	Value map[string]CVBase
+	// Keys holds the keys of Value in the order they were added
+	Keys []string
 }
 
 func MakeCVForgeMap(value any, inheritedCVTagInfo CVTagInfo) (CVForgeMap, bool) {
@@ -17,47 +19,84 @@ func MakeCVForgeMap(value any, inheritedCVTagInfo CVTagInfo) (CVForgeMap, bool)
 	if value == nil {
 		return CVForgeMap{CVTagInfo: info}, false
 	}
-	if m, ok := value.(map[string]any); ok {
-		info = CVTagInfoFromMap(m)
+	if om, ok := asOrderedMap(value); ok {
+		info = CVTagInfoFromMap(om.Values)
 		info.inherit(inheritedCVTagInfo)
-		cvm := make(map[string]CVBase)
-		for k, v := range m {
+		cvm := CVForgeMap{
+			CVTagInfo: info,
+			Value:     make(map[string]CVBase),
+		}
+		for _, k := range om.Keys {
 			if len(strings.TrimSpace(k)) == 0 {
 				continue
 			}
-			if cvb, ok := UnmarshalCVBase(v,info); ok {
-				cvm[k] = cvb
+			if cvb, ok := UnmarshalCVBase(om.Values[k],info); ok {
+				cvm.Set(k, cvb)
 			}
 		}
-		return CVForgeMap{
-			CVTagInfo: info,
-			Value:     cvm,
-		}, true
+		return cvm, true
 	}
 	return CVForgeMap{CVTagInfo: info}, false
 }
 
-func (cm CVForgeMap) Filter(tags []string) (data CVBase, passed bool) {
-	m := cm.Copy().(CVForgeMap)
-    keys := make([]string, 0)
+// Set adds or replaces key, keeping its position if it already exists.
+func (m *CVForgeMap) Set(key string, value CVBase) {
+	if m.Value == nil {
+		m.Value = make(map[string]CVBase)
+	}
+	if _, exists := m.Value[key]; !exists {
+		m.Keys = append(m.Keys, key)
+	}
+	m.Value[key] = value
+}
+
+// Delete removes key from the map and its ordering.
+func (m *CVForgeMap) Delete(key string) {
+	if _, exists := m.Value[key]; !exists {
+		return
+	}
+	delete(m.Value, key)
+	m.Keys = slices.DeleteFunc(m.Keys, func(k string) bool { return k == key })
+}
+
+// OrderedKeys returns the keys in insertion order. Keys missing from the
+// order (e.g. added to Value directly) follow in sorted order.
+func (m CVForgeMap) OrderedKeys() []string {
+	keys := make([]string, 0, len(m.Value))
+	for _, k := range m.Keys {
+		if _, exists := m.Value[k]; exists && !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == len(m.Value) {
+		return keys
+	}
+	var rest []string
 	for k := range m.Value {
-		keys = append(keys, k)
+		if !slices.Contains(keys, k) {
+			rest = append(rest, k)
+		}
 	}
-	for i:=0; i < len(keys); i++ {
-		key := keys[i]
-		value, passed := m.Value[key].Filter(tags)
+	slices.Sort(rest)
+	return append(keys, rest...)
+}
+
+func (cm CVForgeMap) Filter(expr TagExpr) (data CVBase, passed bool) {
+	m := cm.Copy().(CVForgeMap)
+	for _, key := range m.OrderedKeys() {
+		value, passed := m.Value[key].Filter(expr)
 		if passed {
 			m.Value[key] = value
 		} else {
-			delete(m.Value, key)
+			m.Delete(key)
 		}
 	}
-	return m, m.FilterPass(tags)
+	return m, m.FilterPass(expr)
 }
 func (m CVForgeMap) GetEveryTag() []string {
 	tags := m.Tags[:]
-	for _, v := range m.Value {
-		vTags := v.GetEveryTag()
+	for _, k := range m.OrderedKeys() {
+		vTags := m.Value[k].GetEveryTag()
 		for _, tag := range vTags {
 			if !slices.Contains(tags, tag) {
 				tags = append(tags, tag)
@@ -67,12 +106,12 @@ func (m CVForgeMap) GetEveryTag() []string {
 	return tags
 }
 func (m CVForgeMap) Copy() CVBase {
-	cvm := make(map[string]CVBase)
-	for k, v := range m.Value {
-		cvm[k] = v.Copy()
-	}
-	return CVForgeMap{
+	cvm := CVForgeMap{
 		CVTagInfo: m.CVTagInfo,
-		Value:     cvm,
+		Value:     make(map[string]CVBase),
+	}
+	for _, k := range m.OrderedKeys() {
+		cvm.Set(k, m.Value[k].Copy())
 	}
+	return cvm
 }
@@ -0,0 +1,214 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OrderedMap is a decoded mapping that remembers the order of its keys.
+// DecodeYAML and DecodeJSON produce it in place of map[string]any so
+// CVForgeMap can keep the order the data file was written in.
+type OrderedMap struct {
+	Keys   []string
+	Values map[string]any
+}
+
+// Set adds or replaces key, appending it to Keys the first time it is seen.
+func (m *OrderedMap) Set(key string, value any) {
+	if m.Values == nil {
+		m.Values = make(map[string]any)
+	}
+	if _, exists := m.Values[key]; !exists {
+		m.Keys = append(m.Keys, key)
+	}
+	m.Values[key] = value
+}
+
+// asOrderedMap accepts both OrderedMap and a plain map[string]any. Plain maps
+// have no order of their own, so their keys are sorted for stable output.
+func asOrderedMap(value any) (OrderedMap, bool) {
+	switch m := value.(type) {
+	case OrderedMap:
+		return m, true
+	case *OrderedMap:
+		if m == nil {
+			return OrderedMap{}, false
+		}
+		return *m, true
+	case map[string]any:
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return OrderedMap{Keys: keys, Values: m}, true
+	}
+	return OrderedMap{}, false
+}
+
+// DecodeYAML decodes a YAML document, keeping mapping key order.
+func DecodeYAML(data []byte) (any, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 {
+		return nil, nil
+	}
+	return fromYAMLNode(&doc)
+}
+
+func fromYAMLNode(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return fromYAMLNode(node.Content[0])
+	case yaml.AliasNode:
+		return fromYAMLNode(node.Alias)
+	case yaml.SequenceNode:
+		list := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			v, err := fromYAMLNode(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case yaml.MappingNode:
+		var m OrderedMap
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			v, err := fromYAMLNode(valueNode)
+			if err != nil {
+				return nil, err
+			}
+			if keyNode.Tag == "!!merge" {
+				mergeYAMLInto(&m, v)
+				continue
+			}
+			m.Set(keyNode.Value, v)
+		}
+		return m, nil
+	default:
+		var v any
+		if err := node.Decode(&v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// mergeYAMLInto applies a "<<" merge key: entries are added only when the
+// mapping does not already define them.
+func mergeYAMLInto(m *OrderedMap, merged any) {
+	if list, ok := merged.([]any); ok {
+		for _, item := range list {
+			mergeYAMLInto(m, item)
+		}
+		return
+	}
+	if src, ok := merged.(OrderedMap); ok {
+		for _, k := range src.Keys {
+			if _, exists := m.Values[k]; !exists {
+				m.Set(k, src.Values[k])
+			}
+		}
+	}
+}
+
+// DecodeJSON decodes a JSON document, keeping object key order.
+func DecodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			var m OrderedMap
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := keyTok.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid object key %v", keyTok)
+				}
+				v, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				m.Set(key, v)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return m, nil
+		case '[':
+			list := make([]any, 0)
+			for dec.More() {
+				v, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return list, nil
+		}
+		return nil, fmt.Errorf("unexpected delimiter %v", t)
+	default:
+		return t, nil
+	}
+}
+
+// MergeRaw merges two decoded documents. Mappings are merged key by key,
+// keeping base's order and appending keys only found in override; any other
+// value in override replaces the one in base.
+func MergeRaw(base, override any) any {
+	b, bok := asOrderedMap(base)
+	o, ook := asOrderedMap(override)
+	if !bok || !ook {
+		if override == nil {
+			return base
+		}
+		return override
+	}
+
+	var merged OrderedMap
+	for _, k := range b.Keys {
+		merged.Set(k, b.Values[k])
+	}
+	for _, k := range o.Keys {
+		if existing, ok := merged.Values[k]; ok {
+			merged.Set(k, MergeRaw(existing, o.Values[k]))
+		} else {
+			merged.Set(k, o.Values[k])
+		}
+	}
+	return merged
+}
@@ -0,0 +1,106 @@
+package types
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// ListItem is one item of a CVForgeSlice, addressed by its path in the data
+// such as "experience[0].responsibilities[2]"
+type ListItem struct {
+	Path     string
+	Priority float64
+	Value    CVBase
+}
+
+// ListItems returns every list item in cv ordered by how readily it can be
+// dropped: lowest priority first and, among equal priorities, items further
+// down the document first.
+func ListItems(cv CVBase) []ListItem {
+	var items []ListItem
+	collectListItems(cv, "", &items)
+
+	order := make(map[string]int, len(items))
+	for i, item := range items {
+		order[item.Path] = i
+	}
+	slices.SortStableFunc(items, func(a, b ListItem) int {
+		if c := cmp.Compare(a.Priority, b.Priority); c != 0 {
+			return c
+		}
+		return cmp.Compare(order[b.Path], order[a.Path])
+	})
+	return items
+}
+
+// collectListItems gathers the items of every list below cv. Meta keys
+// such as tags configure their value, so their lists are not content.
+func collectListItems(cv CVBase, path string, items *[]ListItem) {
+	switch v := cv.(type) {
+	case CVForgeSlice:
+		for i, item := range v.Value {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			*items = append(*items, ListItem{Path: itemPath, Priority: itemPriority(item), Value: item})
+			collectListItems(item, itemPath, items)
+		}
+	case CVForgeMap:
+		for _, k := range v.OrderedKeys() {
+			if slices.Contains(MetaKeys, k) {
+				continue
+			}
+			keyPath := k
+			if path != "" {
+				keyPath = path + "." + k
+			}
+			collectListItems(v.Value[k], keyPath, items)
+		}
+	}
+}
+
+func itemPriority(cv CVBase) float64 {
+	switch v := cv.(type) {
+	case CVForgeString:
+		return v.Priority
+	case CVForgeSlice:
+		return v.Priority
+	case CVForgeMap:
+		return v.Priority
+	}
+	return 0
+}
+
+// DropItems returns a copy of cv without the list items at paths, as
+// returned by ListItems
+func DropItems(cv CVBase, paths []string) CVBase {
+	drop := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		drop[p] = true
+	}
+	return dropItems(cv.Copy(), "", drop)
+}
+
+func dropItems(cv CVBase, path string, drop map[string]bool) CVBase {
+	switch v := cv.(type) {
+	case CVForgeSlice:
+		kept := v.Value[:0]
+		for i, item := range v.Value {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if !drop[itemPath] {
+				kept = append(kept, dropItems(item, itemPath, drop))
+			}
+		}
+		v.Value = kept
+		return v
+	case CVForgeMap:
+		for _, k := range v.OrderedKeys() {
+			keyPath := k
+			if path != "" {
+				keyPath = path + "." + k
+			}
+			v.Value[k] = dropItems(v.Value[k], keyPath, drop)
+		}
+		return v
+	}
+	return cv
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestListItemsSkipsMetaKeys(t *testing.T) {
+	data, err := os.ReadFile("../examples/example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := DecodeYAML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cv, ok := UnmarshalCVBase(raw, DefaultCVTagInfo())
+	if !ok {
+		t.Fatal("example.yaml did not unmarshal")
+	}
+
+	// Only the content lists of the example, not their tags
+	lists := map[string]int{
+		"links":                          2,
+		"experience":                     2,
+		"experience[0].responsibilities": 8,
+		"experience[1].responsibilities": 8,
+		"education":                      2,
+		"skills.mobile":                  3,
+		"skills.backend":                 7,
+		"skills.databases":               4,
+		"skills.tools":                   7,
+		"languages":                      2,
+	}
+	index := regexp.MustCompile(`\[\d+\]$`)
+	got := make(map[string]int)
+	for _, item := range ListItems(cv) {
+		list := index.ReplaceAllString(item.Path, "")
+		if _, ok := lists[list]; !ok {
+			t.Errorf("unexpected drop candidate %s", item.Path)
+		}
+		got[list]++
+	}
+	for list, n := range lists {
+		if got[list] != n {
+			t.Errorf("%s has %d drop candidates, want %d", list, got[list], n)
+		}
+	}
+}
+
+func TestListItemsOrder(t *testing.T) {
+	raw, err := DecodeYAML([]byte(`
+items:
+  - a
+  - value: b
+    priority: 2
+  - c
+  - value: d
+    priority: -1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cv, _ := UnmarshalCVBase(raw, DefaultCVTagInfo())
+
+	var paths []string
+	for _, item := range ListItems(cv) {
+		paths = append(paths, item.Path)
+	}
+	want := []string{"items[3]", "items[2]", "items[0]", "items[1]"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("ListItems order = %q, want %q", paths, want)
+	}
+}
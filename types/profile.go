@@ -0,0 +1,92 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ProfilesKey is the top-level data key declaring named tag profiles:
+//
+//	_profiles:
+//	  backend: [go, .net, nginx]
+//	  cloud: "(azure | gcp) & ci/cd"
+//
+// A list keeps anything tagged with any of its tags; a string is a tag
+// expression.
+const ProfilesKey = "_profiles"
+
+// Profile is a named tag filter declared in the data file
+type Profile struct {
+	Name string
+	Expr TagExpr
+}
+
+// Profiles lists the profiles of a data file in the order they were written
+type Profiles []Profile
+
+// Lookup returns the profile called name
+func (p Profiles) Lookup(name string) (Profile, bool) {
+	i := slices.IndexFunc(p, func(profile Profile) bool { return profile.Name == name })
+	if i == -1 {
+		return Profile{}, false
+	}
+	return p[i], true
+}
+
+// Names returns the profile names in order
+func (p Profiles) Names() []string {
+	names := make([]string, len(p))
+	for i, profile := range p {
+		names[i] = profile.Name
+	}
+	return names
+}
+
+// ExtractProfiles removes the ProfilesKey entry from decoded data and parses
+// it. The returned data no longer holds the profiles, so they are never
+// rendered.
+func ExtractProfiles(raw any) (any, Profiles, error) {
+	m, ok := asOrderedMap(raw)
+	if !ok || m.Values[ProfilesKey] == nil {
+		return raw, nil, nil
+	}
+
+	declared, ok := asOrderedMap(m.Values[ProfilesKey])
+	if !ok {
+		return nil, nil, fmt.Errorf("%s must map profile names to tags", ProfilesKey)
+	}
+	profiles := make(Profiles, 0, len(declared.Keys))
+	for _, name := range declared.Keys {
+		expr, err := profileExpr(declared.Values[name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s.%s: %w", ProfilesKey, name, err)
+		}
+		profiles = append(profiles, Profile{Name: name, Expr: expr})
+	}
+
+	var stripped OrderedMap
+	for _, k := range m.Keys {
+		if k != ProfilesKey {
+			stripped.Set(k, m.Values[k])
+		}
+	}
+	return stripped, profiles, nil
+}
+
+func profileExpr(value any) (TagExpr, error) {
+	switch v := value.(type) {
+	case string:
+		return ParseTagExpr(v)
+	case []any:
+		tags := make([]string, 0, len(v))
+		for _, tag := range v {
+			s, ok := tag.(string)
+			if !ok {
+				return nil, fmt.Errorf("tags must be strings, got %v", tag)
+			}
+			tags = append(tags, s)
+		}
+		return AnyTag(tags...), nil
+	}
+	return nil, fmt.Errorf("expected a list of tags or a tag expression")
+}
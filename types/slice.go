@@ -22,7 +22,8 @@ func MakeCVForgeSlice(value any, inheritedCVTagInfo CVTagInfo) (CVForgeSlice, bo
 			}
 		}
 	}
-	if m, ok := value.(map[string]any); ok && m["value"] != nil {
+	if om, ok := asOrderedMap(value); ok && om.Values["value"] != nil {
+		m := om.Values
 		info = CVTagInfoFromMap(m)
 		info.inherit(inheritedCVTagInfo)
 		if sl, ok := m["value"].([]any); ok {
@@ -42,17 +43,17 @@ func MakeCVForgeSlice(value any, inheritedCVTagInfo CVTagInfo) (CVForgeSlice, bo
 		Value:     slm,
 	}, len(slm) > 0
 }
-func (cs CVForgeSlice) Filter(tags []string) (data CVBase, passed bool) {
+func (cs CVForgeSlice) Filter(expr TagExpr) (data CVBase, passed bool) {
 	s := cs.Copy().(CVForgeSlice)
 	filtered := s.Value[:0] // aynı array'i kullanır, GC yok
 	for i := range s.Value {
-		f, passed := s.Value[i].Filter(tags)
+		f, passed := s.Value[i].Filter(expr)
 		if passed {
 			filtered = append(filtered, f)
 		}
 	}
 	s.Value = filtered
-	return s, s.FilterPass(tags)
+	return s, s.FilterPass(expr)
 }
 
 func (s CVForgeSlice) GetEveryTag() []string {
@@ -19,8 +19,9 @@ func MakeCVForgeString(value any, inheritedCVTagInfo CVTagInfo) (CVForgeString,
 			CVTagInfo: info,
 			Value: fmt.Sprintf("%v", value),
 		}, true
-	case map[string]any:
-		m := value.(map[string]any)
+	}
+	if om, ok := asOrderedMap(value); ok {
+		m := om.Values
 		info = CVTagInfoFromMap(m)
 		info.inherit(inheritedCVTagInfo)
 		if m["value"] == nil {
@@ -39,8 +40,8 @@ func MakeCVForgeString(value any, inheritedCVTagInfo CVTagInfo) (CVForgeString,
 	return CVForgeString{CVTagInfo: info}, false
 }
 
-func (s CVForgeString) Filter(tags []string) (data CVBase, passed bool) {
-	if s.FilterPass(tags) {
+func (s CVForgeString) Filter(expr TagExpr) (data CVBase, passed bool) {
+	if s.FilterPass(expr) {
 		return s.Copy(), true
 	}
 	return s.Copy(), false
@@ -0,0 +1,195 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TagExpr is a boolean expression over tags such as
+// "go & (azure | gcp) & !legacy". Filter evaluates it against the tags of
+// every tagged node.
+type TagExpr interface {
+	Match(tags []string) bool
+	String() string
+}
+
+type tagName string
+
+type tagNot struct{ expr TagExpr }
+
+type tagAnd []TagExpr
+
+type tagOr []TagExpr
+
+func (t tagName) Match(tags []string) bool { return slices.Contains(tags, string(t)) }
+func (t tagName) String() string           { return string(t) }
+
+func (n tagNot) Match(tags []string) bool { return !n.expr.Match(tags) }
+func (n tagNot) String() string {
+	if _, ok := n.expr.(tagName); !ok {
+		if _, ok := n.expr.(tagNot); !ok {
+			return "!(" + n.expr.String() + ")"
+		}
+	}
+	return "!" + n.expr.String()
+}
+
+func (a tagAnd) Match(tags []string) bool {
+	for _, e := range a {
+		if !e.Match(tags) {
+			return false
+		}
+	}
+	return true
+}
+func (a tagAnd) String() string { return joinTagExprs(a, " & ") }
+
+func (o tagOr) Match(tags []string) bool {
+	for _, e := range o {
+		if e.Match(tags) {
+			return true
+		}
+	}
+	return false
+}
+func (o tagOr) String() string { return joinTagExprs(o, " | ") }
+
+func joinTagExprs(exprs []TagExpr, sep string) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = e.String()
+		if _, nested := e.(tagName); !nested && len(exprs) > 1 {
+			if _, not := e.(tagNot); !not {
+				parts[i] = "(" + parts[i] + ")"
+			}
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// AnyTag matches nodes carrying any of tags. It is what the comma
+// separated --tags list means.
+func AnyTag(tags ...string) TagExpr {
+	or := make(tagOr, 0, len(tags))
+	for _, tag := range tags {
+		if tag = normalizeTag(tag); tag != "" {
+			or = append(or, tagName(tag))
+		}
+	}
+	return or
+}
+
+// normalizeTag lowercases and trims a tag the way tags in data are stored
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// ParseTagExpr parses a tag expression. Tags are combined with & (and),
+// | or , (or) and ! (not), grouped with parentheses; & binds tighter than |.
+// Tag names are case-insensitive and may contain any other character,
+// including spaces, as in "ci/cd" or "machine learning".
+func ParseTagExpr(s string) (TagExpr, error) {
+	p := &tagParser{src: s}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != 0 {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return expr, nil
+}
+
+type tagParser struct {
+	src string
+	pos int
+}
+
+const tagOperators = "&|,!()"
+
+// peek skips spaces and returns the next byte, or 0 at the end
+func (p *tagParser) peek() byte {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	if p.pos == len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tagParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("tag expression %q at column %d: %s", p.src, p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *tagParser) parseOr() (TagExpr, error) {
+	var or tagOr
+	for {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, expr)
+		if c := p.peek(); c != '|' && c != ',' {
+			break
+		}
+		p.pos++
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *tagParser) parseAnd() (TagExpr, error) {
+	var and tagAnd
+	for {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, expr)
+		if p.peek() != '&' {
+			break
+		}
+		p.pos++
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *tagParser) parseUnary() (TagExpr, error) {
+	switch c := p.peek(); c {
+	case 0:
+		return nil, p.errorf("expected a tag")
+	case '!':
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return tagNot{expr}, nil
+	case '(':
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.errorf("missing )")
+		}
+		p.pos++
+		return expr, nil
+	case '&', '|', ',', ')':
+		return nil, p.errorf("expected a tag, found %q", c)
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(tagOperators, rune(p.src[p.pos])) {
+		p.pos++
+	}
+	return tagName(normalizeTag(p.src[start:p.pos])), nil
+}
@@ -0,0 +1,128 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTagExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // String() of the parsed expression
+	}{
+		{"go", "go"},
+		{"  Go  ", "go"},
+		{"a & b", "a & b"},
+		{"a | b", "a | b"},
+		{"a, b", "a | b"},
+		{"a | b & c", "a | (b & c)"},
+		{"a & b | c", "(a & b) | c"},
+		{"a & (b | c)", "a & (b | c)"},
+		{"(a | b) & !c", "(a | b) & !c"},
+		{"!a & b", "!a & b"},
+		{"!(a & b)", "!(a & b)"},
+		{"!!a", "!!a"},
+		{"((a))", "a"},
+		{"a | b, c", "a | b | c"},
+		{"ci/cd & machine learning", "ci/cd & machine learning"},
+		{"C++ | c#", "c++ | c#"},
+		{"a&b|c", "(a & b) | c"},
+		{"\ta\t&\tb", "a & b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := ParseTagExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseTagExpr(%q): %v", tt.expr, err)
+			}
+			if got := expr.String(); got != tt.want {
+				t.Errorf("ParseTagExpr(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagExprMatch(t *testing.T) {
+	tests := []struct {
+		expr string
+		tags string
+		want bool
+	}{
+		{"go", "go", true},
+		{"GO", "go", true},
+		{"go", "golang", false},
+		{"go & azure", "go,azure", true},
+		{"go & azure", "go", false},
+		{"go | azure", "azure", true},
+		{"go | azure", "gcp", false},
+		{"!legacy", "go", true},
+		{"!legacy", "legacy", false},
+		{"!legacy", "", true},
+		{"go & (azure | gcp) & !legacy", "go,gcp", true},
+		{"go & (azure | gcp) & !legacy", "go,gcp,legacy", false},
+		{"go & (azure | gcp) & !legacy", "go", false},
+		{"a | b & c", "a", true},
+		{"a | b & c", "b", false},
+		{"(a | b) & c", "a", false},
+		{"machine learning", "machine learning", true},
+		{"machine learning", "machine", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr+"/"+tt.tags, func(t *testing.T) {
+			expr, err := ParseTagExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseTagExpr(%q): %v", tt.expr, err)
+			}
+			var tags []string
+			if tt.tags != "" {
+				tags = strings.Split(tt.tags, ",")
+			}
+			if got := expr.Match(tags); got != tt.want {
+				t.Errorf("%q.Match(%q) = %v, want %v", tt.expr, tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTagExprErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "at column 1: expected a tag"},
+		{"   ", "at column 4: expected a tag"},
+		{"a &", "at column 4: expected a tag"},
+		{"a | | b", `at column 5: expected a tag, found '|'`},
+		{"& a", `at column 1: expected a tag, found '&'`},
+		{"a, ,b", `at column 4: expected a tag, found ','`},
+		{"!", "at column 2: expected a tag"},
+		{"(a | b", "at column 7: missing )"},
+		{"((a)", "at column 5: missing )"},
+		{"a)", `at column 2: unexpected ')'`},
+		{"()", `at column 2: expected a tag, found ')'`},
+		{"a & (b | c))", `at column 12: unexpected ')'`},
+		{"a (b)", `at column 3: unexpected '('`},
+		{"a !b", `at column 3: unexpected '!'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseTagExpr(tt.expr)
+			if err == nil {
+				t.Fatalf("ParseTagExpr(%q) succeeded, want an error", tt.expr)
+			}
+			if !strings.HasSuffix(err.Error(), tt.want) {
+				t.Errorf("ParseTagExpr(%q) error = %q, want it to end with %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnyTag(t *testing.T) {
+	expr := AnyTag(" Go", "", "Azure ")
+	if got := expr.String(); got != "go | azure" {
+		t.Errorf("AnyTag().String() = %q, want %q", got, "go | azure")
+	}
+	if !expr.Match([]string{"azure"}) || expr.Match([]string{"gcp"}) {
+		t.Errorf("AnyTag(go, azure) matched the wrong tags")
+	}
+}
@@ -1,7 +1,7 @@
 package types
 
 import (
-	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -9,8 +9,17 @@ type CVTagInfo struct {
 	Tags      []string `yaml:"tags,omitempty"`
 	URL       string   `yaml:"url,omitempty"`
 	Exclusive bool     `yaml:"exclusive,omitempty"`
+	// Format tells the engine how to insert the value: "text" (escaped,
+	// the default), "html" (trusted markup inserted as is) or "markdown"
+	Format string `yaml:"format,omitempty"`
+	// Priority ranks list items for --max-pages: items with the lowest
+	// priority are dropped first. Set with the priority or weight meta key.
+	Priority float64 `yaml:"priority,omitempty"`
 }
 
+// MetaKeys are data keys that configure a value rather than being rendered
+var MetaKeys = []string{"tags", "url", "exclusive", "format", "markdown", "priority", "weight"}
+
 func DefaultCVTagInfo() CVTagInfo {
 	return CVTagInfo{Tags: make([]string, 0), URL: "", Exclusive: false}
 }
@@ -18,9 +27,38 @@ func (t *CVTagInfo) inherit(inheritedCVTagInfo CVTagInfo) {
 	if t.URL == "" {
 		t.URL = inheritedCVTagInfo.URL
 	}
+	if t.Format == "" {
+		t.Format = inheritedCVTagInfo.Format
+	}
 
 }
 
+func parsePriority(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// SplitTags parses a comma-separated tag list the way tags in data are read
+func SplitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = normalizeTag(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
 func CVTagInfoFromMap(m map[string]any) CVTagInfo {
 	info := DefaultCVTagInfo()
 	if m == nil {
@@ -30,14 +68,12 @@ func CVTagInfoFromMap(m map[string]any) CVTagInfo {
 		if _, ok := m["tags"].([]any); ok {
 			for _, tag := range m["tags"].([]any) {
 				if t, ok := tag.(string); ok {
-					info.Tags = append(info.Tags, strings.ToLower(t))
+					info.Tags = append(info.Tags, normalizeTag(t))
 				}
 			}
 		}
 		if _, ok := m["tags"].(string); ok {
-			for _, tag := range strings.Split(m["tags"].(string), ",") {
-				info.Tags = append(info.Tags, strings.ToLower(tag))
-			}
+			info.Tags = append(info.Tags, SplitTags(m["tags"].(string))...)
 		}
 	}
 	if m["url"] != nil {
@@ -45,6 +81,19 @@ func CVTagInfoFromMap(m map[string]any) CVTagInfo {
 			info.URL = m["url"].(string)
 		}
 	}
+	if m["format"] != nil {
+		if _, ok := m["format"].(string); ok {
+			info.Format = strings.ToLower(strings.TrimSpace(m["format"].(string)))
+		}
+	}
+	if markdown, ok := m["markdown"].(bool); ok && markdown {
+		info.Format = "markdown"
+	}
+	for _, key := range []string{"priority", "weight"} {
+		if priority, ok := parsePriority(m[key]); ok {
+			info.Priority = priority
+		}
+	}
 	if m["exclusive"] != nil {
 		if _, ok := m["exclusive"].(bool); ok {
 			info.Exclusive = m["exclusive"].(bool)
@@ -61,13 +110,11 @@ func CVTagInfoFromMap(m map[string]any) CVTagInfo {
 
 }
 
-func (t *CVTagInfo) FilterPass(tags []string) (pass bool) {
-
-	for _, tag := range t.Tags {
-		pass = slices.Contains(tags, tag)
-		if pass {
-			break
-		}
+// FilterPass reports whether the node's own tags satisfy expr. Untagged
+// nodes always pass.
+func (t *CVTagInfo) FilterPass(expr TagExpr) (pass bool) {
+	if len(t.Tags) > 0 {
+		pass = expr.Match(t.Tags)
 	}
 	if !pass && t.Exclusive {
 		return false